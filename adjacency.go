@@ -0,0 +1,143 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+)
+
+// FaceContact represents a pair of block faces that are touching each other
+type FaceContact struct {
+	A, B         Block
+	FaceA, FaceB Facing
+	// Area is the area of the touching region
+	Area float64
+	// Pair is the MaterialPair between the two faces' materials,
+	// it will be nil if any of the material is nil or the pair is not registered
+	Pair *MaterialPair
+}
+
+// touchingFace returns the face of the cube that touching the other cube, and the area of the touching region
+func touchingFace(l, x *Cube) (f Facing, area float64, ok bool) {
+	p1, p2 := l.Pos(), l.EndPos()
+	q1, q2 := x.Pos(), x.EndPos()
+	area = 1
+	axis := -1
+	for i, a := range [3][4]float64{
+		{p1.X, p2.X, q1.X, q2.X},
+		{p1.Y, p2.Y, q1.Y, q2.Y},
+		{p1.Z, p2.Z, q1.Z, q2.Z},
+	} {
+		lo, hi := math.Max(a[0], a[2]), math.Min(a[1], a[3])
+		switch {
+		case hi-lo > faceEpsilon:
+			area *= hi - lo
+		case hi-lo >= -faceEpsilon && axis == -1:
+			axis = i
+			if math.Abs(a[1]-a[2]) <= faceEpsilon {
+				f = AxisFacings[i][0]
+			} else {
+				f = AxisFacings[i][1]
+			}
+		default:
+			return 0, 0, false
+		}
+	}
+	if axis == -1 {
+		return 0, 0, false
+	}
+	return f, area, true
+}
+
+func lookupPair(set *MaterialSet, a, b *Material) *MaterialPair {
+	if set == nil || a == nil || b == nil {
+		return nil
+	}
+	return set.GetPair(a, b)
+}
+
+// FaceContacts returns the touching face pairs between the blocks inside the object.
+// Each pair is reported once, with the MaterialPair looked up from the MaterialSet
+func (o *Object) FaceContacts(set *MaterialSet) (contacts []FaceContact) {
+	o.RLock()
+	defer o.RUnlock()
+
+	for _, b := range o.blocks {
+		l := b.Outline()
+		// only look at the positive faces, so each pair will be visited once
+		for _, fs := range AxisFacings {
+			f := fs[0]
+			o.index.QueryBox(facingSlab(l, f), func(a Block) bool {
+				if a == b {
+					return true
+				}
+				if fa, area, ok := touchingFace(l, a.Outline()); ok && fa == f {
+					contacts = append(contacts, FaceContact{
+						A:     b,
+						B:     a,
+						FaceA: f,
+						FaceB: fs[1],
+						Area:  area,
+						Pair:  lookupPair(set, b.Material(f), a.Material(fs[1])),
+					})
+				}
+				return true
+			})
+		}
+	}
+	return
+}
+
+// FaceContactsWith returns the touching face pairs between the blocks of the two objects.
+// Block A is from the object that FaceContactsWith is called on, and block B is from the other object.
+// Only the blocks that are aligned with each other (rotated by multiples of 90°) can touch with faces
+func (o *Object) FaceContactsWith(a *Object, set *MaterialSet) (contacts []FaceContact) {
+	disp := o.RelPos(a)
+
+	o.RLock()
+	defer o.RUnlock()
+	a.RLock()
+	defer a.RUnlock()
+
+	t := o.frameTransformLocked(a, disp)
+	if !t.Aligned() {
+		// the blocks can only touch with edges or corners
+		return nil
+	}
+	for _, ab := range a.blocks {
+		x := t.Cube(ab.Outline())
+		o.index.QueryBox(x, func(b Block) bool {
+			f, area, ok := touchingFace(b.Outline(), x)
+			if !ok {
+				return true
+			}
+			// convert the opposite face normal into the other object's local space
+			n := f.Opposite().Normal().RotatedXYZ(o.angle).UnrotatedXYZ(a.angle)
+			fb := FacingOf(n)
+			contacts = append(contacts, FaceContact{
+				A:     b,
+				B:     ab,
+				FaceA: f,
+				FaceB: fb,
+				Area:  area,
+				Pair:  lookupPair(set, b.Material(f), ab.Material(fb)),
+			})
+			return true
+		})
+	}
+	return
+}
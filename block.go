@@ -16,6 +16,10 @@
 
 package molecular
 
+import (
+	"math"
+)
+
 type Facing uint8
 
 //go:generate stringer -type=Facing
@@ -28,13 +32,109 @@ const (
 	BACK
 )
 
+// AxisFacings maps the axis index (X, Y, Z) and the direction (positive, negative) to the Facing
+var AxisFacings = [3][2]Facing{
+	{RIGHT, LEFT},
+	{TOP, BOTTOM},
+	{FRONT, BACK},
+}
+
+var facingNormals = [...]Vec3{
+	TOP:    UnitY,
+	BOTTOM: {0, -1, 0},
+	LEFT:   {-1, 0, 0},
+	RIGHT:  UnitX,
+	FRONT:  UnitZ,
+	BACK:   {0, 0, -1},
+}
+
+// FacingOf returns the Facing whose normal is the closest to the vector
+func FacingOf(v Vec3) Facing {
+	a := v.Abs()
+	axis, n := 0, v.X
+	if a.Y > a.X && a.Y >= a.Z {
+		axis, n = 1, v.Y
+	} else if a.Z > a.X && a.Z > a.Y {
+		axis, n = 2, v.Z
+	}
+	if n < 0 {
+		return AxisFacings[axis][1]
+	}
+	return AxisFacings[axis][0]
+}
+
+// Axis returns the axis index (X, Y, Z) and the direction of the Facing
+func (f Facing) Axis() (axis int, neg bool) {
+	for i, fs := range AxisFacings {
+		if fs[0] == f {
+			return i, false
+		}
+		if fs[1] == f {
+			return i, true
+		}
+	}
+	panic("molecular.Facing: Unknown facing value")
+}
+
+// Normal returns the outward unit normal vector of the Facing
+func (f Facing) Normal() Vec3 {
+	if int(f) >= len(facingNormals) {
+		panic("molecular.Facing: Unknown facing value")
+	}
+	return facingNormals[f]
+}
+
+// Opposite returns the Facing on the other side of the same axis
+func (f Facing) Opposite() Facing {
+	axis, neg := f.Axis()
+	if neg {
+		return AxisFacings[axis][0]
+	}
+	return AxisFacings[axis][1]
+}
+
+// Mirror returns the Facing mirrored by the plane that perpendicular to the axis
+func (f Facing) Mirror(axis int) Facing {
+	if a, _ := f.Axis(); a == axis {
+		return f.Opposite()
+	}
+	return f
+}
+
+// rotate90 rotates the Facing by n * 90 degrees with the rotate function
+func (f Facing) rotate90(n int, rotate func(v Vec3, angle float64) Vec3) Facing {
+	n %= 4
+	if n == 0 {
+		return f
+	}
+	return FacingOf(rotate(f.Normal(), (float64)(n)*math.Pi/2))
+}
+
+// RotateX returns the Facing rotated by n * 90 degrees about the X axis, same direction as Vec3.RotateX
+func (f Facing) RotateX(n int) Facing {
+	return f.rotate90(n, Vec3.RotatedX)
+}
+
+// RotateY returns the Facing rotated by n * 90 degrees about the Y axis, same direction as Vec3.RotateY
+func (f Facing) RotateY(n int) Facing {
+	return f.rotate90(n, Vec3.RotatedY)
+}
+
+// RotateZ returns the Facing rotated by n * 90 degrees about the Z axis, same direction as Vec3.RotateZ
+func (f Facing) RotateZ(n int) Facing {
+	return f.rotate90(n, Vec3.RotatedZ)
+}
+
 type Block interface {
 	// SetObject will be called when block is inited or it's moving between objects
 	SetObject(o *Object)
 	Mass() float64
 	// Material returns the material of the face, nil is allowed
 	Material(f Facing) *Material
-	// Outline specific the position and the maximum space of the block
+	// Outline specific the position and the maximum space of the block.
+	// It must return the block's own cube, which is not shared with other blocks,
+	// since the engine writes to it in place when the block is moved into another object
+	// or its invalid outline is restored
 	Outline() *Cube
 	// Tick will be called when the block need to update it's state
 	Tick(dt float64)
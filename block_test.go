@@ -0,0 +1,202 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+type testBlock struct {
+	obj      *Object
+	mass     float64
+	outline  *Cube
+	material *Material
+	temp     float64
+}
+
+func newTestBlock(pos Vec3, mass float64, material *Material) *testBlock {
+	return &testBlock{
+		mass:     mass,
+		outline:  NewCube(pos, OneVec),
+		material: material,
+	}
+}
+
+func (b *testBlock) SetObject(o *Object)         { b.obj = o }
+func (b *testBlock) Mass() float64               { return b.mass }
+func (b *testBlock) Material(f Facing) *Material { return b.material }
+func (b *testBlock) Outline() *Cube              { return b.outline }
+func (b *testBlock) Tick(dt float64)             {}
+func (b *testBlock) Temperature() float64        { return b.temp }
+
+// ballBlock is a block which collision shape is the sphere inscribed in its outline
+type ballBlock struct {
+	*testBlock
+}
+
+func newBallBlock(pos Vec3, mass float64) *ballBlock {
+	return &ballBlock{newTestBlock(pos, mass, nil)}
+}
+
+func (b *ballBlock) Shape() Shape {
+	return NewSphere(b.outline.Center(), b.outline.S.X/2)
+}
+
+var (
+	glassMaterial = NewMaterial("glass", MaterialProps{Brittleness: 0.9, Density: 2500, Durability: 10})
+	steelMaterial = NewMaterial("steel", MaterialProps{Brittleness: 0.1, Density: 7850, Durability: 100})
+)
+
+func TestBlockBreak(t *testing.T) {
+	var events []*BlockBreakEvent
+	e := NewEngine(Config{
+		OnBlockBreak: func(event *BlockBreakEvent) {
+			events = append(events, event)
+		},
+	})
+	glass := newTestBlock(ZeroVec, 1, glassMaterial)
+	steel := newTestBlock(UnitX, 1, steelMaterial)
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(glass, steel)
+	})
+	e.Tick(time.Millisecond)
+
+	o.ApplyImpulse(glass, Vec3{0, 6e3, 0})
+	o.ApplyImpulse(steel, Vec3{0, 6e3, 0})
+	e.Tick(time.Millisecond)
+	if len(events) != 1 {
+		t.Fatalf("Expect 1 break event, got %d", len(events))
+	}
+	if events[0].Block != glass {
+		t.Errorf("Expect glass block to break, got %v", events[0].Block)
+	}
+	debris := events[0].Debris
+	if debris == nil {
+		t.Fatalf("Expect brittle glass block to shatter into debris")
+	}
+	if blocks := o.Blocks(); len(blocks) != 1 || blocks[0] != steel {
+		t.Errorf("Expect only steel block left, got %v", blocks)
+	}
+	if !debris.Velocity().Equals(o.Velocity()) {
+		t.Errorf("Expect debris inherit velocity %v, got %v", o.Velocity(), debris.Velocity())
+	}
+	e.Tick(time.Millisecond)
+	if glass.obj != debris {
+		t.Errorf("Expect glass block moved to debris object")
+	}
+
+	steel.temp = 1000
+	e.Tick(time.Millisecond)
+	steel.temp = 0
+	e.Tick(time.Millisecond)
+	if len(events) != 2 || events[1].Block != steel || events[1].Debris != nil {
+		t.Errorf("Expect steel block destroyed by thermal stress")
+	}
+}
+
+func TestBlockFaceContacts(t *testing.T) {
+	set := NewMaterialSet()
+	set.Add(glassMaterial)
+	set.Add(steelMaterial)
+	pair := &MaterialPair{MatterA: glassMaterial, MatterB: steelMaterial, SCOF: 0.5, KCOF: 0.4}
+	set.AddPair(pair)
+
+	e := NewEngine(Config{})
+	glass := newTestBlock(ZeroVec, 1, glassMaterial)
+	steel := newTestBlock(UnitX, 1, steelMaterial)
+	o1 := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(glass, steel)
+	})
+	other := newTestBlock(ZeroVec, 1, glassMaterial)
+	o2 := e.NewObject(ManMadeObj, nil, UnitY, func(o *Object) {
+		o.AddBlock(other)
+	})
+	e.Tick(time.Millisecond)
+
+	contacts := o1.FaceContacts(set)
+	if len(contacts) != 1 {
+		t.Fatalf("Expect 1 face contact, got %v", contacts)
+	}
+	if c := contacts[0]; c.A != glass || c.B != steel || c.FaceA != RIGHT || c.FaceB != LEFT || c.Area != 1 || c.Pair != pair {
+		t.Errorf("Unexpected face contact %#v", c)
+	}
+
+	contacts = o1.FaceContactsWith(o2, set)
+	if len(contacts) != 1 {
+		t.Fatalf("Expect 1 face contact between objects, got %v", contacts)
+	}
+	if c := contacts[0]; c.A != glass || c.B != other || c.FaceA != TOP || c.FaceB != BOTTOM || c.Pair != nil {
+		t.Errorf("Unexpected face contact %#v", c)
+	}
+
+	// the blocks rotated by multiples of 90° still touch with faces, others do not
+	square := e.NewObject(ManMadeObj, nil, UnitY, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, glassMaterial))
+		o.SetAngle(Vec3{0, 0, math.Pi / 2})
+	})
+	tilted := e.NewObject(ManMadeObj, nil, UnitY, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, glassMaterial))
+		o.SetAngle(Vec3{0, 0, 0.5})
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	if contacts := o1.FaceContactsWith(square, set); len(contacts) != 1 || contacts[0].FaceA != TOP {
+		t.Errorf("Expect 1 face contact with the object rotated by 90°, got %v", contacts)
+	}
+	if contacts := o1.FaceContactsWith(tilted, set); len(contacts) != 0 {
+		t.Errorf("Expect no face contact with the tilted object, got %v", contacts)
+	}
+}
+
+func TestFacingRotate(t *testing.T) {
+	for f := TOP; f <= BACK; f++ {
+		if FacingOf(f.Normal()) != f {
+			t.Errorf("Expect FacingOf(%v.Normal()) to be %v", f, f)
+		}
+		if n := f.Opposite().Normal(); !n.Negated().Equals(f.Normal()) {
+			t.Errorf("Expect %v opposite to %v", f.Opposite(), f)
+		}
+		if g := f.RotateX(1).RotateY(2).RotateZ(-3); g.RotateZ(3).RotateY(-2).RotateX(-1) != f {
+			t.Errorf("Expect rotation of %v reversible", f)
+		}
+	}
+	if f := RIGHT.RotateY(1); f != BACK {
+		t.Errorf("Expect RIGHT rotated 90 degrees about Y to be BACK, got %v", f)
+	}
+	if f := TOP.RotateZ(1); f != LEFT {
+		t.Errorf("Expect TOP rotated 90 degrees about Z to be LEFT, got %v", f)
+	}
+	if f := LEFT.Mirror(0); f != RIGHT {
+		t.Errorf("Expect LEFT mirrored on X to be RIGHT, got %v", f)
+	}
+
+	e := NewEngine(Config{})
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.SetAngle(Vec3{math.Pi / 2, 0, 0})
+	})
+	e.Tick(time.Millisecond)
+	if f := o.WorldFacing(TOP); f != TOP.RotateX(1) {
+		t.Errorf("Expect world facing %v, got %v", TOP.RotateX(1), f)
+	}
+	if f := o.LocalFacing(o.WorldFacing(FRONT)); f != FRONT {
+		t.Errorf("Expect local facing FRONT, got %v", f)
+	}
+}
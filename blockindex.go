@@ -0,0 +1,317 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+)
+
+const (
+	defaultBlockCellSize = 1
+
+	// maxBlockCells is the maximum cells that a block can be put in,
+	// larger blocks will be saved separately and checked linearly
+	maxBlockCells = 64
+)
+
+type blockCoord struct {
+	X, Y, Z int64
+}
+
+// blockIndex is a sparse voxel grid that indexes the blocks by their outlines in object space
+type blockIndex struct {
+	cellSize float64
+	cells    map[blockCoord][]Block
+	large    []Block
+	// outlines saves the outlines when the blocks were added,
+	// so they can be removed correctly even if the outlines changed.
+	// The object re-indexes the changed blocks after each tick
+	outlines map[Block]Cube
+}
+
+func newBlockIndex(cellSize float64) *blockIndex {
+	return &blockIndex{
+		cellSize: cellSize,
+		cells:    make(map[blockCoord][]Block),
+		outlines: make(map[Block]Cube),
+	}
+}
+
+// cellRange returns the inclusive cell range of the cube.
+// If exclusive is true, the cells that only touched by the end faces are excluded,
+// otherwise the cells that touched by any face are included
+func (x *blockIndex) cellRange(c *Cube, exclusive bool) (lo, hi blockCoord) {
+	p, q := c.Pos(), c.EndPos()
+	if exclusive {
+		lo = blockCoord{
+			(int64)(math.Floor(p.X / x.cellSize)),
+			(int64)(math.Floor(p.Y / x.cellSize)),
+			(int64)(math.Floor(p.Z / x.cellSize)),
+		}
+		hi = blockCoord{
+			max(lo.X, (int64)(math.Ceil(q.X/x.cellSize))-1),
+			max(lo.Y, (int64)(math.Ceil(q.Y/x.cellSize))-1),
+			max(lo.Z, (int64)(math.Ceil(q.Z/x.cellSize))-1),
+		}
+	} else {
+		lo = blockCoord{
+			(int64)(math.Ceil(p.X/x.cellSize)) - 1,
+			(int64)(math.Ceil(p.Y/x.cellSize)) - 1,
+			(int64)(math.Ceil(p.Z/x.cellSize)) - 1,
+		}
+		hi = blockCoord{
+			(int64)(math.Floor(q.X / x.cellSize)),
+			(int64)(math.Floor(q.Y / x.cellSize)),
+			(int64)(math.Floor(q.Z / x.cellSize)),
+		}
+	}
+	return
+}
+
+func cellCount(lo, hi blockCoord) float64 {
+	return (float64)(hi.X-lo.X+1) * (float64)(hi.Y-lo.Y+1) * (float64)(hi.Z-lo.Z+1)
+}
+
+func (x *blockIndex) Len() int {
+	return len(x.outlines)
+}
+
+func (x *blockIndex) Add(b Block) {
+	if _, ok := x.outlines[b]; ok {
+		x.Remove(b)
+	}
+	l := *b.Outline()
+	x.outlines[b] = l
+	lo, hi := x.cellRange(&l, true)
+	if cellCount(lo, hi) > maxBlockCells {
+		x.large = append(x.large, b)
+		return
+	}
+	for i := lo.X; i <= hi.X; i++ {
+		for j := lo.Y; j <= hi.Y; j++ {
+			for k := lo.Z; k <= hi.Z; k++ {
+				c := blockCoord{i, j, k}
+				x.cells[c] = append(x.cells[c], b)
+			}
+		}
+	}
+}
+
+func (x *blockIndex) Remove(b Block) {
+	l, ok := x.outlines[b]
+	if !ok {
+		return
+	}
+	delete(x.outlines, b)
+	lo, hi := x.cellRange(&l, true)
+	if cellCount(lo, hi) > maxBlockCells {
+		x.large = removeFromSlice(x.large, b)
+		return
+	}
+	for i := lo.X; i <= hi.X; i++ {
+		for j := lo.Y; j <= hi.Y; j++ {
+			for k := lo.Z; k <= hi.Z; k++ {
+				c := blockCoord{i, j, k}
+				if blocks := removeFromSlice(x.cells[c], b); len(blocks) == 0 {
+					delete(x.cells, c)
+				} else {
+					x.cells[c] = blocks
+				}
+			}
+		}
+	}
+}
+
+// Stale reports whether the block is indexed with an outline other than l.
+// A non-finite outline is never indexed, so the last finite one can be restored
+func (x *blockIndex) Stale(b Block, l *Cube) bool {
+	old, ok := x.outlines[b]
+	return ok && old != *l && isFiniteCube(l)
+}
+
+// Outline returns the outline of the block when it was indexed
+func (x *blockIndex) Outline(b Block) (l Cube, ok bool) {
+	l, ok = x.outlines[b]
+	return
+}
+
+// Update re-indexes the block if it's still indexed and its outline has changed
+func (x *blockIndex) Update(b Block) {
+	if x.Stale(b, b.Outline()) {
+		x.Add(b)
+	}
+}
+
+// Reset replaces all the blocks in the index
+func (x *blockIndex) Reset(blocks []Block) {
+	clear(x.cells)
+	clear(x.outlines)
+	clear(x.large)
+	x.large = x.large[:0]
+	for _, b := range blocks {
+		x.Add(b)
+	}
+}
+
+// QueryBox invokes the callback on each block that overlapping or touching the cube.
+// If the callback returns false, the query will stop
+func (x *blockIndex) QueryBox(c *Cube, cb func(b Block) bool) {
+	for _, b := range x.large {
+		if c.Overlap(b.Outline()) && !cb(b) {
+			return
+		}
+	}
+	lo, hi := x.cellRange(c, false)
+	var seen set[Block]
+	check := func(blocks []Block) bool {
+		for _, b := range blocks {
+			if !c.Overlap(b.Outline()) {
+				continue
+			}
+			if seen == nil {
+				seen = make(set[Block], 4)
+			} else if seen.Has(b) {
+				continue
+			}
+			seen.Put(b)
+			if !cb(b) {
+				return false
+			}
+		}
+		return true
+	}
+	if cellCount(lo, hi) > (float64)(len(x.cells)) {
+		for _, blocks := range x.cells {
+			if !check(blocks) {
+				return
+			}
+		}
+		return
+	}
+	for i := lo.X; i <= hi.X; i++ {
+		for j := lo.Y; j <= hi.Y; j++ {
+			for k := lo.Z; k <= hi.Z; k++ {
+				if !check(x.cells[blockCoord{i, j, k}]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// QueryPoint returns the first block that contains the point
+func (x *blockIndex) QueryPoint(p Vec3) (block Block) {
+	x.QueryBox(&Cube{P: p}, func(b Block) bool {
+		block = b
+		return false
+	})
+	return
+}
+
+// Neighbours appends the blocks that sharing the face f of the block to the slice.
+// The blocks only touching the face along an edge or a corner are excluded
+func (x *blockIndex) Neighbours(blocks []Block, b Block, f Facing) []Block {
+	l := b.Outline()
+	slab := facingSlab(l, f)
+	x.QueryBox(slab, func(a Block) bool {
+		if a == b {
+			return true
+		}
+		if face, area, ok := touchingFace(l, a.Outline()); ok && face == f && area > 0 {
+			blocks = append(blocks, a)
+		}
+		return true
+	})
+	return blocks
+}
+
+// facingSlab returns a thin cube that covers the face of the cube
+func facingSlab(c *Cube, f Facing) *Cube {
+	slab := *c
+	axis, neg := f.Axis()
+	switch axis {
+	case 0:
+		if !neg {
+			slab.P.X += slab.S.X
+		}
+		slab.S.X = 0
+	case 1:
+		if !neg {
+			slab.P.Y += slab.S.Y
+		}
+		slab.S.Y = 0
+	case 2:
+		if !neg {
+			slab.P.Z += slab.S.Z
+		}
+		slab.S.Z = 0
+	}
+	return &slab
+}
+
+func removeFromSlice[T comparable](s []T, v T) []T {
+	last := len(s) - 1
+	for i, a := range s {
+		if a == v {
+			s[i] = s[last]
+			var zero T
+			s[last] = zero
+			return s[:last]
+		}
+	}
+	return s
+}
+
+// BlockAt returns the block that contains the point in object space, or nil if there is none
+func (o *Object) BlockAt(p Vec3) Block {
+	o.RLock()
+	defer o.RUnlock()
+	return o.index.QueryPoint(p)
+}
+
+// BlocksInBox returns the blocks that overlapping or touching the cube in object space
+func (o *Object) BlocksInBox(c *Cube) (blocks []Block) {
+	o.RLock()
+	defer o.RUnlock()
+	o.index.QueryBox(c, func(b Block) bool {
+		blocks = append(blocks, b)
+		return true
+	})
+	return
+}
+
+// Neighbours returns the blocks that sharing the face f of the block
+func (o *Object) Neighbours(b Block, f Facing) []Block {
+	o.RLock()
+	defer o.RUnlock()
+	return o.index.Neighbours(nil, b, f)
+}
+
+// queryRelBoxLocked invokes the callback on each block that may overlap with the box.
+// The box is axis-aligned and relative to the object's zero position, the object's orientation will be applied.
+// The object has to be read locked
+func (o *Object) queryRelBoxLocked(box *Cube, cb func(b Block) bool) {
+	center := box.Center().Subbed(o.gcenter).UnrotatedXYZ(o.angle).Added(o.gcenter)
+	half := box.S.ScaledN(0.5)
+	half = UnitX.UnrotatedXYZ(o.angle).Abs().ScaledN(half.X).
+		Added(UnitY.UnrotatedXYZ(o.angle).Abs().ScaledN(half.Y)).
+		Added(UnitZ.UnrotatedXYZ(o.angle).Abs().ScaledN(half.Z))
+	o.index.QueryBox(&Cube{
+		P: center.Subbed(half),
+		S: half.ScaledN(2),
+	}, cb)
+}
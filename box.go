@@ -16,6 +16,10 @@
 
 package molecular
 
+import (
+	"math"
+)
+
 type Cube struct {
 	P Vec3 // Pos
 	S Vec3 // Size
@@ -73,15 +77,44 @@ func (b *Cube) Center() Vec3 {
 	return b.P.Added(b.S.ScaledN(0.5))
 }
 
-// Overlap will return if the two Cube overlapped or not
+// Support returns the corner of the Cube that is farthest along the direction
+func (b *Cube) Support(dir Vec3) Vec3 {
+	p := b.P
+	if dir.X > 0 {
+		p.X += b.S.X
+	}
+	if dir.Y > 0 {
+		p.Y += b.S.Y
+	}
+	if dir.Z > 0 {
+		p.Z += b.S.Z
+	}
+	return p
+}
+
+// Bounds returns a copy of the Cube
+func (b *Cube) Bounds() *Cube {
+	c := *b
+	return &c
+}
+
+// Extend expands the Cube to contain the other Cube
+func (b *Cube) Extend(x *Cube) *Cube {
+	p1, p2 := b.Pos(), b.EndPos()
+	q1, q2 := x.Pos(), x.EndPos()
+	b.P = Vec3{math.Min(p1.X, q1.X), math.Min(p1.Y, q1.Y), math.Min(p1.Z, q1.Z)}
+	b.S = Vec3{math.Max(p2.X, q2.X), math.Max(p2.Y, q2.Y), math.Max(p2.Z, q2.Z)}.Subbed(b.P)
+	return b
+}
+
+// Overlap will return if the two Cube overlapped or touched.
+// It's also true when one Cube contains the other on any axis
 func (b *Cube) Overlap(x *Cube) bool {
 	p1, p2 := b.Pos(), b.EndPos()
 	q1, q2 := x.Pos(), x.EndPos()
-	a1, a2 := q1.Subbed(p1), p2.Subbed(q1)
-	b1, b2 := q2.Subbed(p1), p2.Subbed(q2)
-	return (a1.X >= 0 && a2.X >= 0 || b1.X >= 0 && b2.X >= 0) &&
-		(a1.Y >= 0 && a2.Y >= 0 || b1.Y >= 0 && b2.Y >= 0) &&
-		(a1.Z >= 0 && a2.Z >= 0 || b1.Z >= 0 && b2.Z >= 0)
+	return p1.X <= q2.X && q1.X <= p2.X &&
+		p1.Y <= q2.Y && q1.Y <= p2.Y &&
+		p1.Z <= q2.Z && q1.Z <= p2.Z
 }
 
 // OverlapBox will calcuate the overlapped area.
@@ -103,6 +136,9 @@ func (b *Cube) OverlapBox(x *Cube, area *Cube) bool {
 	} else if b1.X >= 0 && b2.X >= 0 { // q1-p1-q2-p2
 		area.P.X = 0
 		area.S.X = b1.X
+	} else if a1.X < 0 && b2.X < 0 { // q1-p1-p2-q2
+		area.P.X = 0
+		area.S.X = p2.X - p1.X
 	} else {
 		return false
 	}
@@ -116,6 +152,9 @@ func (b *Cube) OverlapBox(x *Cube, area *Cube) bool {
 	} else if b1.Y >= 0 && b2.Y >= 0 {
 		area.P.Y = 0
 		area.S.Y = b1.Y
+	} else if a1.Y < 0 && b2.Y < 0 {
+		area.P.Y = 0
+		area.S.Y = p2.Y - p1.Y
 	} else {
 		return false
 	}
@@ -129,8 +168,38 @@ func (b *Cube) OverlapBox(x *Cube, area *Cube) bool {
 	} else if b1.Z >= 0 && b2.Z >= 0 {
 		area.P.Z = 0
 		area.S.Z = b1.Z
+	} else if a1.Z < 0 && b2.Z < 0 {
+		area.P.Z = 0
+		area.S.Z = p2.Z - p1.Z
 	} else {
 		return false
 	}
 	return true
 }
+
+// faceEpsilon is the tolerance when checking if two faces are touching
+const faceEpsilon = 1e-9
+
+// SharesFace will return if the two Cube are touching with a face,
+// which means they are touching on one axis and overlapped with a positive area on the other two axes
+func (b *Cube) SharesFace(x *Cube) bool {
+	p1, p2 := b.Pos(), b.EndPos()
+	q1, q2 := x.Pos(), x.EndPos()
+	touch, overlap := 0, 0
+	for _, a := range [3][4]float64{
+		{p1.X, p2.X, q1.X, q2.X},
+		{p1.Y, p2.Y, q1.Y, q2.Y},
+		{p1.Z, p2.Z, q1.Z, q2.Z},
+	} {
+		lo, hi := math.Max(a[0], a[2]), math.Min(a[1], a[3])
+		switch {
+		case hi-lo > faceEpsilon:
+			overlap++
+		case hi-lo >= -faceEpsilon:
+			touch++
+		default:
+			return false
+		}
+	}
+	return touch == 1 && overlap == 2
+}
@@ -40,6 +40,11 @@ func TestBoxOverlap(t *testing.T) {
 		{NewCube(ZeroVec, OneVec.ScaledN(2)), NewCube(OneVec, OneVec), NewCube(OneVec, OneVec)},
 		{NewCube(ZeroVec, OneVec.ScaledN(2)), NewCube(OneVec, OneVec.ScaledN(2)), NewCube(OneVec, OneVec)},
 		{NewCube(ZeroVec, NegOneVec.ScaledN(2)), NewCube(OneVec, NegOneVec.ScaledN(2)), NewCube(OneVec, OneVec)},
+		// one cube contains the other
+		{NewCube(ZeroVec, OneVec.ScaledN(3)), NewCube(OneVec, OneVec), NewCube(OneVec, OneVec)},
+		{NewCube(OneVec, OneVec), NewCube(ZeroVec, OneVec.ScaledN(3)), NewCube(ZeroVec, OneVec)},
+		// crossed bars, each one contains the other on one axis
+		{NewCube(Vec3{0, 1, 0}, Vec3{3, 1, 1}), NewCube(Vec3{1, 0, 0}, Vec3{1, 3, 1}), NewCube(UnitX, OneVec)},
 	}
 	area := new(Cube)
 	for _, d := range datas {
@@ -57,3 +62,27 @@ func TestBoxOverlap(t *testing.T) {
 		}
 	}
 }
+
+func TestBoxSharesFace(t *testing.T) {
+	type T struct {
+		A, B  *Cube
+		Share bool
+	}
+	datas := []T{
+		{NewCube(ZeroVec, OneVec), NewCube(UnitX, OneVec), true},
+		{NewCube(ZeroVec, OneVec), NewCube(UnitY.Negated(), OneVec), true},
+		{NewCube(ZeroVec, OneVec), NewCube(Vec3{1, 0.5, 0}, OneVec), true},
+		{NewCube(ZeroVec, OneVec), NewCube(Vec3{1, 1, 0}, OneVec), false},
+		{NewCube(ZeroVec, OneVec), NewCube(OneVec, OneVec), false},
+		{NewCube(ZeroVec, OneVec), NewCube(Vec3{2, 0, 0}, OneVec), false},
+		{NewCube(ZeroVec, OneVec), NewCube(Vec3{0.5, 0, 0}, OneVec), false},
+	}
+	for _, d := range datas {
+		if s := d.A.SharesFace(d.B); s != d.Share {
+			t.Errorf("Incorrect SharesFace result %v for cubes %v & %v, expect %v", s, d.A, d.B, d.Share)
+		}
+		if s := d.B.SharesFace(d.A); s != d.Share {
+			t.Errorf("Incorrect SharesFace result %v for cubes %v & %v, expect %v", s, d.B, d.A, d.Share)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+)
+
+// ImpactEvent will be passed to Config.OnImpact when continuous collision detection finds a hit
+type ImpactEvent struct {
+	// Object is the fast moving object
+	Object *Object
+	// Hit is where the object hits, the distance is the travelled distance before the impact
+	Hit RayHit
+	// Impulse is the impulse applied on the object, the hit object receives the negated impulse
+	Impulse Vec3
+}
+
+// CCD reports whether continuous collision detection is always enabled for the object
+func (o *Object) CCD() bool {
+	return o.ccd.Load()
+}
+
+// SetCCD sets whether continuous collision detection is always enabled for the object.
+// Otherwise, it's only enabled when the speed reaches Config.CCDSpeed
+func (o *Object) SetCCD(enabled bool) {
+	o.ccd.Store(enabled)
+}
+
+// worldHalfExtents returns the half extents of the bounding box of the rotated cube
+func worldHalfExtents(half Vec3, angle Vec3) Vec3 {
+	return UnitX.RotatedXYZ(angle).Abs().ScaledN(half.X).
+		Added(UnitY.RotatedXYZ(angle).Abs().ScaledN(half.Y)).
+		Added(UnitZ.RotatedXYZ(angle).Abs().ScaledN(half.Z))
+}
+
+// ccdSweep describes an object that moved further than its own extent during the tick
+type ccdSweep struct {
+	obj      *Object
+	boxes    []ccdBox // the bounding boxes of the blocks
+	disp     Vec3     // the displacement during the tick
+	velocity Vec3     // the velocity after the tick in the main anchor space
+}
+
+// ccdBox is the bounding box of a block in absolute space
+type ccdBox struct {
+	center Vec3
+	half   Vec3
+}
+
+// needCCDLocked checks if the object needs continuous collision detection.
+// The object has to be read locked, and the next status has to be locked
+func (o *Object) needCCDLocked() (disp Vec3, ok bool) {
+	if len(o.blocks) == 0 || o.mass <= 0 {
+		return
+	}
+	if !o.ccd.Load() {
+		speed := o.e.cfg.CCDSpeed
+		if speed <= 0 || o.nextStatus.velocity.SqLen() < speed*speed {
+			return
+		}
+	}
+	disp = o.nextStatus.pos.Subbed(o.pos)
+	s := o.bounds.S
+	extent := math.Min(s.X, math.Min(s.Y, s.Z))
+	return disp, disp.SqLen() > extent*extent
+}
+
+// tickCCD sweeps the fast objects from their current positions to the next positions.
+// The bounding box of each block is swept separately, so the empty space inside the object's bounds
+// does not hit anything. The blocks that are already touching at the start of the tick,
+// and the hits that the objects are moving apart are ignored.
+// If an object hits a block, it will be moved to the time of impact,
+// and an inelastic impulse along the hit normal will be applied on both objects.
+// The hit objects are treated as static during the sweep
+func (e *Engine) tickCCD() {
+	e.RLock()
+	var sweeps []ccdSweep
+	for _, o := range e.objects {
+		abs := o.AbsPos()
+		o.RLock()
+		o.nextMux.RLock()
+		if disp, ok := o.needCCDLocked(); ok {
+			boxes := make([]ccdBox, len(o.blocks))
+			for i, b := range o.blocks {
+				l := b.Outline()
+				boxes[i] = ccdBox{
+					center: abs.Added(o.gcenter).Added(l.Center().Subbed(o.gcenter).RotatedXYZ(o.angle)),
+					half:   worldHalfExtents(l.S.ScaledN(0.5), o.angle),
+				}
+			}
+			sweeps = append(sweeps, ccdSweep{
+				obj:      o,
+				boxes:    boxes,
+				disp:     disp,
+				velocity: e.absVelocityFrom(o.nextStatus.velocity, o.anchor),
+			})
+		}
+		o.nextMux.RUnlock()
+		o.RUnlock()
+	}
+	e.RUnlock()
+
+	for _, s := range sweeps {
+		o := s.obj
+		filter := func(a *Object) bool {
+			return a != o
+		}
+		// accept is called with the hit object read locked, so its velocity can be read directly.
+		// The velocities are compared in the main anchor space, since the objects may have different anchors
+		accept := func(h *RayHit) bool {
+			return s.velocity.Subbed(e.absVelocityFrom(h.Object.velocity, h.Object.anchor)).Dot(h.Normal) < 0
+		}
+		dist := s.disp.Len()
+		var (
+			hit RayHit
+			ok  bool
+		)
+		maxDist := dist
+		for _, b := range s.boxes {
+			h, hitted := e.sweep(&sweepQuery{
+				origin:    b.center,
+				half:      b.half,
+				dir:       s.disp,
+				skipStart: true,
+				accept:    accept,
+			}, maxDist, filter)
+			if hitted {
+				hit, ok = h, true
+				maxDist = h.Distance
+			}
+		}
+		if !ok {
+			continue
+		}
+		impulse := o.impactImpulse(&hit)
+
+		o.RLock()
+		o.nextMux.Lock()
+		o.nextStatus.pos = o.pos.Added(s.disp.ScaledN(hit.Distance / dist))
+		o.ApplyImpulseLocked(nil, impulse)
+		o.nextMux.Unlock()
+		o.RUnlock()
+		hit.Object.ApplyImpulse(hit.Block, impulse.Negated())
+
+		if e.cfg.OnImpact != nil {
+			e.cfg.OnImpact(&ImpactEvent{
+				Object:  o,
+				Hit:     hit,
+				Impulse: impulse,
+			})
+		}
+	}
+}
+
+// impactImpulse calculates the impulse that the object receives when it hits the block.
+// The coefficient of restitution is read from the material of the hit face.
+// The velocities of the objects are composed into the main anchor space first
+func (o *Object) impactImpulse(hit *RayHit) (impulse Vec3) {
+	o.RLock()
+	o.nextMux.RLock()
+	vo, mo := o.e.absVelocityFrom(o.nextStatus.velocity, o.anchor), o.mass
+	o.nextMux.RUnlock()
+	o.RUnlock()
+	a := hit.Object
+	a.RLock()
+	va, ma := o.e.absVelocityFrom(a.velocity, a.anchor), a.mass
+	a.RUnlock()
+
+	vn := vo.Subbed(va).Dot(hit.Normal)
+	if vn >= 0 {
+		return
+	}
+	var cor float64
+	if m := hit.Block.Material(hit.Face); m != nil {
+		cor = m.props.COR
+	}
+	inv := 1 / mo
+	if ma > 0 {
+		inv += 1 / ma
+	}
+	return hit.Normal.ScaledN(-(1 + cor) * vn / inv)
+}
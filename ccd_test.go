@@ -0,0 +1,112 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+// newCCDRig creates a thin heavy wall at x = 10, and a fast object whose block starts at x
+func newCCDRig(x float64, impacts *[]*ImpactEvent) (e *Engine, bullet, wall *Object) {
+	e = NewEngine(Config{
+		OnImpact: func(event *ImpactEvent) {
+			*impacts = append(*impacts, event)
+		},
+	})
+	plate := newTestBlock(ZeroVec, 1e9, nil)
+	plate.outline = NewCube(Vec3{0, -5, -5}, Vec3{0.1, 10, 10})
+	wall = e.NewObject(ManMadeObj, nil, Vec3{10, 0, 0}, func(o *Object) {
+		o.AddBlock(plate)
+	})
+	bullet = e.NewObject(ManMadeObj, nil, Vec3{x, 0, 0}, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetCCD(true)
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	return
+}
+
+func TestCCDTunnelling(t *testing.T) {
+	var impacts []*ImpactEvent
+	e, bullet, wall := newCCDRig(0, &impacts)
+	bullet.SetVelocity(Vec3{1000, 0, 0})
+	// moves 50m in the first tick, which passes through the wall without CCD
+	e.Tick(100 * time.Millisecond)
+	if len(impacts) != 1 || impacts[0].Hit.Object != wall {
+		t.Fatalf("Expect one impact on the wall, got %v", impacts)
+	}
+	if x := bullet.Pos().X; x > 9+1e-6 || x < 8 {
+		t.Errorf("Expect the bullet stops in front of the wall, got x=%v", x)
+	}
+	if v := bullet.Velocity().X; v > 1e-3 {
+		t.Errorf("Expect the bullet no longer moves toward the wall, got %v", v)
+	}
+}
+
+func TestCCDSeparatingWhileTouching(t *testing.T) {
+	var impacts []*ImpactEvent
+	// the bullet is touching the wall's face at x = 10
+	e, bullet, _ := newCCDRig(9, &impacts)
+	bullet.SetVelocity(Vec3{-1000, 0, 0})
+	e.Tick(100 * time.Millisecond)
+	if len(impacts) != 0 {
+		t.Fatalf("Expect no impact when moving away from the wall, got %v", impacts)
+	}
+	if x := bullet.Pos().X; x > -40 {
+		t.Errorf("Expect the bullet moves away freely, got x=%v", x)
+	}
+}
+
+func TestCCDDifferentAnchors(t *testing.T) {
+	var impacts []*ImpactEvent
+	e := NewEngine(Config{
+		OnImpact: func(event *ImpactEvent) {
+			impacts = append(impacts, event)
+		},
+	})
+	// the wall is carried by an object that moves away faster than the bullet
+	carrier := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(Vec3{0, 1000, 0}, 1, nil))
+	})
+	plate := newTestBlock(ZeroVec, 1e9, nil)
+	plate.outline = NewCube(Vec3{0, -5, -5}, Vec3{0.1, 10, 10})
+	wall := e.NewObject(ManMadeObj, carrier, Vec3{10, 0, 0}, func(o *Object) {
+		o.AddBlock(plate)
+	})
+	bullet := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetCCD(true)
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	carrier.SetVelocity(Vec3{2000, 0, 0})
+	bullet.SetVelocity(Vec3{1000, 0, 0})
+	e.Tick(time.Millisecond)
+
+	// the bullet is faster than the wall relative to their own anchors, but the wall is moving away
+	e.Tick(100 * time.Millisecond)
+	if len(impacts) != 0 {
+		t.Fatalf("Expect no impact on the separating wall, got %v", impacts)
+	}
+	if v := wall.AbsVelocity().X; v < 1999 {
+		t.Errorf("Expect the wall keeps moving, got %v", v)
+	}
+}
@@ -0,0 +1,169 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+)
+
+// CollisionPolicy decides what happens when two objects collide
+type CollisionPolicy uint8
+
+const (
+	// CollideNone will not do anything
+	CollideNone CollisionPolicy = iota
+	// CollideAccrete merges the two objects inelastically into one body,
+	// the lost kinetic energy will be released as heat
+	CollideAccrete
+)
+
+// HeatBlock is a Block that can absorb heat
+type HeatBlock interface {
+	Block
+	// AddHeat adds heat in J to the block
+	AddHeat(heat float64)
+}
+
+// AccretionEvent will be passed to Config.OnAccretion after two bodies merged
+type AccretionEvent struct {
+	// Body is the object that absorbed the other one
+	Body *Object
+	// Absorbed is the object that was removed from the engine
+	Absorbed *Object
+	// Heat is the released heat in J
+	Heat float64
+}
+
+// collisionPolicy returns the policy between the two object types.
+// A policy only applies when both types have the same policy
+func (e *Engine) collisionPolicy(a, b ObjType) CollisionPolicy {
+	p := e.cfg.CollisionPolicies[a]
+	if p != e.cfg.CollisionPolicies[b] {
+		return CollideNone
+	}
+	return p
+}
+
+// isAncestorOf reports whether o is one of the anchors of a
+func (o *Object) isAncestorOf(a *Object) bool {
+	for n := a.Anchor(); n != nil; n = n.Anchor() {
+		if n == o {
+			return true
+		}
+	}
+	return false
+}
+
+// SpheresOverlap reports whether the spheres of the two objects' gravity fields are overlapping
+func (o *Object) SpheresOverlap(a *Object) bool {
+	og, ag := o.GravityField(), a.GravityField()
+	r := og.Radius() + ag.Radius()
+	if r <= 0 {
+		return false
+	}
+	d := o.RelPos(a)
+	d.Add(ag.Pos()).Sub(og.Pos())
+	return d.SqLen() < r*r
+}
+
+// collidesWith reports whether the two bodies are in contact.
+// If both bodies have a radius, their spheres are tested,
+// otherwise the shapes of their blocks are tested by Touching
+func (o *Object) collidesWith(a *Object) bool {
+	if o.GravityField().Radius() > 0 && a.GravityField().Radius() > 0 {
+		return o.SpheresOverlap(a)
+	}
+	return o.Touching(a)
+}
+
+// Accrete merges the other body into this body inelastically.
+// The radius will be set from the combined volume,
+// and the lost kinetic energy will be distributed to the HeatBlock by mass as heat.
+// Accrete returns the released heat in J, and should not be called inside a tick
+func (o *Object) Accrete(a *Object) (heat float64) {
+	og, ag := o.GravityField(), a.GravityField()
+	ro, ra := og.Radius(), ag.Radius()
+	mo, ma := og.Mass(), ag.Mass()
+	if total := mo + ma; total > 0 {
+		speed := o.RelSpeed(a)
+		heat = 0.5 * mo * ma / total * speed * speed
+	}
+
+	o.Merge(a)
+	o.SetRadius(math.Cbrt(ro*ro*ro + ra*ra*ra))
+
+	if heat > 0 {
+		o.RLock()
+		defer o.RUnlock()
+		if o.mass > 0 {
+			for _, b := range o.blocks {
+				if hb, ok := b.(HeatBlock); ok {
+					hb.AddHeat(heat * b.Mass() / o.mass)
+				}
+			}
+		}
+	}
+	return
+}
+
+// accreteObjects merges the colliding objects that using CollideAccrete policy
+func (e *Engine) accreteObjects() {
+	if len(e.cfg.CollisionPolicies) == 0 {
+		return
+	}
+
+	e.RLock()
+	objs := e.objsCache[:0]
+	for _, o := range e.objects {
+		if e.cfg.CollisionPolicies[o.typ] == CollideAccrete {
+			objs = append(objs, o)
+		}
+	}
+	e.RUnlock()
+
+	for i := 0; i < len(objs); i++ {
+		a := objs[i]
+		if a == nil {
+			continue
+		}
+		for j := i + 1; j < len(objs); j++ {
+			b := objs[j]
+			if b == nil || e.collisionPolicy(a.typ, b.typ) != CollideAccrete ||
+				a.RelSpeed(b) < e.cfg.AccretionSpeed || !a.collidesWith(b) {
+				continue
+			}
+			body, absorbed := a, b
+			if !a.isAncestorOf(b) && (b.isAncestorOf(a) || b.GravityField().Mass() > a.GravityField().Mass()) {
+				body, absorbed = b, a
+			}
+			heat := body.Accrete(absorbed)
+			objs[i], objs[j] = body, nil
+			if e.cfg.OnAccretion != nil {
+				e.cfg.OnAccretion(&AccretionEvent{
+					Body:     body,
+					Absorbed: absorbed,
+					Heat:     heat,
+				})
+			}
+			// the merged body has grown and moved, so check it against all the other bodies again
+			i = -1
+			break
+		}
+	}
+	clear(objs)
+	e.objsCache = objs[:0]
+}
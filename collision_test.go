@@ -0,0 +1,148 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func newAccretionEngine(events *[]*AccretionEvent) *Engine {
+	return NewEngine(Config{
+		CollisionPolicies: map[ObjType]CollisionPolicy{
+			NaturalObj: CollideAccrete,
+		},
+		AccretionSpeed: 5,
+		OnAccretion: func(event *AccretionEvent) {
+			*events = append(*events, event)
+		},
+	})
+}
+
+// newTestBody creates a body at rest, its velocity will be set after its mass is ready
+func newTestBody(e *Engine, pos Vec3, mass, radius float64, vel Vec3, pending *[]func()) *Object {
+	o := newTestPlanet(e, nil, pos, mass, radius)
+	*pending = append(*pending, func() { o.SetVelocity(vel) })
+	return o
+}
+
+func startBodies(e *Engine, pending []func()) {
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	for _, f := range pending {
+		f()
+	}
+	// all the accretions must be resolved in a single tick
+	e.Tick(time.Millisecond)
+}
+
+func TestAccretion(t *testing.T) {
+	var (
+		events  []*AccretionEvent
+		pending []func()
+	)
+	e := newAccretionEngine(&events)
+	a := newTestBody(e, ZeroVec, 3, 1, Vec3{X: 10}, &pending)
+	b := newTestBody(e, Vec3{X: 1.5}, 1, 1, Vec3{X: -10}, &pending)
+	startBodies(e, pending)
+	if len(events) != 1 {
+		t.Fatalf("Expect one accretion, got %d", len(events))
+	}
+	if ev := events[0]; ev.Body != a || ev.Absorbed != b || ev.Heat <= 0 {
+		t.Errorf("Expect heavier body absorbs the other with heat, got %+v", ev)
+	}
+	if e.GetObject(b.Id()) != nil {
+		t.Errorf("Expect absorbed body removed")
+	}
+	if v := a.Velocity(); math.Abs(v.X-5) > 1e-6 {
+		t.Errorf("Expect momentum conserving velocity 5, got %v", v)
+	}
+	if r, expect := a.GravityField().Radius(), math.Cbrt(2); math.Abs(r-expect) > 1e-9 {
+		t.Errorf("Expect radius %v from combined volume, got %v", expect, r)
+	}
+}
+
+func TestAccretionSlowContact(t *testing.T) {
+	var (
+		events  []*AccretionEvent
+		pending []func()
+	)
+	e := newAccretionEngine(&events)
+	newTestBody(e, ZeroVec, 1, 1, ZeroVec, &pending)
+	newTestBody(e, Vec3{X: 1.5}, 1, 1, Vec3{X: -1}, &pending)
+	startBodies(e, pending)
+	if len(events) != 0 {
+		t.Errorf("Expect slow bodies not accrete, got %d accretions", len(events))
+	}
+}
+
+func TestAccretionChain(t *testing.T) {
+	// c only overlaps b, so it must be checked against b after a is absorbed,
+	// no matter in which order the bodies are visited
+	for i := 0; i < 20; i++ {
+		var (
+			events  []*AccretionEvent
+			pending []func()
+		)
+		e := newAccretionEngine(&events)
+		newTestBody(e, ZeroVec, 1, 1, Vec3{X: 10}, &pending)
+		b := newTestBody(e, Vec3{X: 1.5}, 10, 1, ZeroVec, &pending)
+		newTestBody(e, Vec3{X: 3.2}, 1, 1, Vec3{X: -10}, &pending)
+		startBodies(e, pending)
+		if len(events) != 2 {
+			t.Fatalf("Expect two accretions, got %d", len(events))
+		}
+		count := 0
+		e.ForeachObject(func(*Object) { count++ })
+		if count != 1 || e.GetObject(b.Id()) == nil {
+			t.Fatalf("Expect only the heaviest body left, got %d objects", count)
+		}
+	}
+}
+
+func TestAccretionShapedBlocks(t *testing.T) {
+	var (
+		events  []*AccretionEvent
+		pending []func()
+	)
+	e := newAccretionEngine(&events)
+	newBall := func(pos, vel Vec3) *Object {
+		o := e.NewObject(NaturalObj, nil, pos, func(o *Object) {
+			o.AddBlock(newBallBlock(ZeroVec, 1))
+		})
+		pending = append(pending, func() { o.SetVelocity(vel) })
+		return o
+	}
+	// the bodies have no radius, so their blocks are tested
+	a := newBall(ZeroVec, Vec3{Z: 5})
+	newBall(Vec3{0.8, 0.8, 0}, Vec3{Z: -5})
+	c := newBall(Vec3{10, 0, 0}, Vec3{Z: 5})
+	d := newBall(Vec3{10.6, 0.6, 0}, Vec3{Z: -5})
+	startBodies(e, pending)
+	if len(events) != 1 {
+		t.Fatalf("Expect 1 accretion, got %d", len(events))
+	}
+	if ev := events[0]; !(ev.Body == c && ev.Absorbed == d || ev.Body == d && ev.Absorbed == c) {
+		t.Errorf("Expect the touching balls merged, got %v and %v", ev.Body, ev.Absorbed)
+	}
+	if len(a.Blocks()) != 1 {
+		t.Errorf("Expect the separated balls are not merged")
+	}
+}
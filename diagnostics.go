@@ -0,0 +1,252 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+	"time"
+)
+
+// Diagnostic is the conserved quantities of a group of objects.
+// The positions and the velocities are measured in a same anchor space
+type Diagnostic struct {
+	// Objects is the count of the objects
+	Objects int
+	// Mass is the total rest mass in kg
+	Mass float64
+	// Kinetic is the relativistic kinetic energy in J, includes the rotational energy
+	Kinetic float64
+	// Potential is the gravitational potential energy in J
+	Potential float64
+	// Momentum is the relativistic linear momentum in kg*m/s
+	Momentum Vec3
+	// AngularMomentum is the angular momentum in kg*m^2/s about the zero position of the anchor space,
+	// includes the spin of the objects
+	AngularMomentum Vec3
+}
+
+// Energy returns the total mechanical energy
+func (d Diagnostic) Energy() float64 {
+	return d.Kinetic + d.Potential
+}
+
+// Diagnostics is the conserved quantities of the engine
+type Diagnostics struct {
+	// Global is measured in the main anchor space, includes all the objects
+	Global Diagnostic
+	// Subtrees is measured in the space of each anchor that has children.
+	// It includes all the descendants of the anchor, and the anchor itself is the fixed source of gravity
+	Subtrees map[*Object]Diagnostic
+}
+
+// diagBody is a snapshot of an object for the diagnostics
+type diagBody struct {
+	o        *Object
+	anchor   *Object
+	children []*diagBody
+	pos      Vec3 // the position relative to the anchor
+	gcenter  Vec3
+	velocity Vec3
+	mass     float64
+	spin     Vec3 // the spin angular momentum
+	spinE    float64
+}
+
+// Diagnostics computes the energy, momentum and angular momentum of the objects.
+// Diagnostics should not be called inside a tick
+func (e *Engine) Diagnostics() (d Diagnostics) {
+	e.RLock()
+	bodies := make(map[*Object]*diagBody, len(e.objects)+1)
+	bodies[e.mainAnchor] = snapshotDiagBody(e.mainAnchor)
+	for _, o := range e.objects {
+		bodies[o] = snapshotDiagBody(o)
+	}
+	e.RUnlock()
+	// the children lists of the objects are not used, since the main anchor does not keep them
+	for _, b := range bodies {
+		if p, ok := bodies[b.anchor]; ok {
+			p.children = append(p.children, b)
+		}
+	}
+
+	d.Subtrees = make(map[*Object]Diagnostic)
+	for o, b := range bodies {
+		if len(b.children) == 0 {
+			continue
+		}
+		d.Subtrees[o] = e.subtreeDiagnostic(b)
+	}
+	d.Global = e.subtreeDiagnostic(bodies[e.mainAnchor])
+	return
+}
+
+func snapshotDiagBody(o *Object) *diagBody {
+	o.RLock()
+	defer o.RUnlock()
+	b := &diagBody{
+		o:        o,
+		anchor:   o.anchor,
+		pos:      o.pos,
+		gcenter:  o.gcenter,
+		velocity: o.velocity,
+		mass:     o.mass,
+	}
+	if o.mass > 0 && o.headVel != ZeroVec {
+		inertia := blocksInertia(o.blocks, o.gcenter)
+		w := o.headVel.UnrotatedXYZ(o.angle)
+		l := Vec3{inertia.X * w.X, inertia.Y * w.Y, inertia.Z * w.Z}
+		b.spinE = l.Dot(w) / 2
+		b.spin = l.RotatedXYZ(o.angle)
+	}
+	return b
+}
+
+// subtreeDiagnostic computes the quantities of the descendants of the root in the root's space
+func (e *Engine) subtreeDiagnostic(root *diagBody) (d Diagnostic) {
+	type relBody struct {
+		pos, velocity Vec3
+		mass          float64
+	}
+	var rels []relBody
+	var walk func(b *diagBody, zero, vel Vec3)
+	walk = func(b *diagBody, zero, vel Vec3) {
+		for _, cb := range b.children {
+			z := zero.Added(cb.pos)
+			p := z.Added(cb.gcenter)
+			// compose the velocities like AbsVelocity does
+			v := cb.velocity.ScaledN(e.ReLorentzFactorSq(vel.SqLen())).Added(vel)
+			rels = append(rels, relBody{p, v, cb.mass})
+			d.Objects++
+			d.Mass += cb.mass
+			d.Kinetic += kineticEnergy(cb.mass, v) + cb.spinE
+			mom := e.Momentum(cb.mass, v)
+			d.Momentum.Add(mom)
+			d.AngularMomentum.Add(p.Cross(mom)).Add(cb.spin)
+			walk(cb, z, v)
+		}
+	}
+	walk(root, ZeroVec, ZeroVec)
+
+	// the root is the fixed source of gravity
+	for _, r := range rels {
+		d.Potential += potentialEnergy(root.mass, r.mass, r.pos.Subbed(root.gcenter).Len())
+	}
+	for i, a := range rels {
+		for _, b := range rels[i+1:] {
+			d.Potential += potentialEnergy(a.mass, b.mass, a.pos.Subbed(b.pos).Len())
+		}
+	}
+	return
+}
+
+// kineticEnergy returns (γ - 1) * m * c^2 without losing the precision at low speed
+func kineticEnergy(mass float64, velocity Vec3) float64 {
+	u := velocity.SqLen() / cSq
+	if u >= 1 {
+		return math.Inf(1)
+	}
+	s := math.Sqrt(1 - u)
+	return mass * cSq * u / (s * (1 + s))
+}
+
+func potentialEnergy(m1, m2 float64, distance float64) float64 {
+	if distance <= 0 || m1 == 0 || m2 == 0 {
+		return 0
+	}
+	return -G * m1 * m2 / distance
+}
+
+// DriftRecord is the drift of the conserved quantities relative to the first record
+type DriftRecord struct {
+	// Time is the elapsed time since the first record
+	Time       time.Duration
+	Diagnostic Diagnostic
+	// Energy is the energy change divided by the sum of the absolute kinetic and potential energy of the first record
+	Energy float64
+	// Momentum is the length of the momentum change
+	Momentum float64
+	// AngularMomentum is the length of the angular momentum change
+	AngularMomentum float64
+}
+
+// DriftRecorder logs the drift of the conserved quantities over time
+type DriftRecorder struct {
+	e       *Engine
+	anchor  *Object
+	elapsed time.Duration
+	base    Diagnostic
+	records []DriftRecord
+}
+
+// NewDriftRecorder creates a recorder and takes the first record immediately.
+// If anchor is not nil, the recorder watches the anchor's subtree, otherwise it watches the global quantities
+func NewDriftRecorder(e *Engine, anchor *Object) (r *DriftRecorder) {
+	r = &DriftRecorder{
+		e:      e,
+		anchor: anchor,
+	}
+	r.base = r.diagnostic()
+	r.records = append(r.records, DriftRecord{Diagnostic: r.base})
+	return
+}
+
+func (r *DriftRecorder) diagnostic() Diagnostic {
+	d := r.e.Diagnostics()
+	if r.anchor == nil {
+		return d.Global
+	}
+	return d.Subtrees[r.anchor]
+}
+
+// Base returns the first record's diagnostic
+func (r *DriftRecorder) Base() Diagnostic {
+	return r.base
+}
+
+// Record takes a new record after dt passed since the last record
+func (r *DriftRecorder) Record(dt time.Duration) DriftRecord {
+	r.elapsed += dt
+	d := r.diagnostic()
+	rec := DriftRecord{
+		Time:            r.elapsed,
+		Diagnostic:      d,
+		Energy:          safeDiv(d.Energy()-r.base.Energy(), math.Abs(r.base.Kinetic)+math.Abs(r.base.Potential)),
+		Momentum:        d.Momentum.Subbed(r.base.Momentum).Len(),
+		AngularMomentum: d.AngularMomentum.Subbed(r.base.AngularMomentum).Len(),
+	}
+	r.records = append(r.records, rec)
+	return rec
+}
+
+// Records returns all the records, include the first one
+func (r *DriftRecorder) Records() []DriftRecord {
+	return r.records
+}
+
+// MaxDrift returns the maximum absolute drift of each quantity in the records,
+// the Time is the elapsed time of the last record
+func (r *DriftRecorder) MaxDrift() (m DriftRecord) {
+	for _, rec := range r.records {
+		if math.Abs(rec.Energy) > math.Abs(m.Energy) {
+			m.Energy = rec.Energy
+		}
+		m.Momentum = max(m.Momentum, rec.Momentum)
+		m.AngularMomentum = max(m.AngularMomentum, rec.AngularMomentum)
+		m.Time = rec.Time
+	}
+	return
+}
@@ -0,0 +1,92 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func TestDiagnostics(t *testing.T) {
+	e := NewEngine(Config{})
+	planet := newTestPlanet(e, nil, ZeroVec, 6e24, 6.4e6)
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	mu := planet.Mass() * G
+	r := 1e7
+	v := math.Sqrt(mu / r)
+	e.NewObject(ManMadeObj, planet, Vec3{X: r}, func(o *Object) {
+		o.AddBlock(newTestBlock(Vec3{X: -0.5, Y: -0.5, Z: -0.5}, 1000, nil))
+		o.SetVelocity(Vec3{Y: v})
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	d, ok := e.Diagnostics().Subtrees[planet]
+	if !ok {
+		t.Fatalf("Expect planet subtree diagnostic")
+	}
+	if d.Objects != 1 || d.Mass != 1000 {
+		t.Errorf("Expect one satellite of 1000kg, got %d objects of %vkg", d.Objects, d.Mass)
+	}
+	if expect := 500 * v * v; math.Abs(d.Kinetic-expect) > expect*1e-6 {
+		t.Errorf("Expect kinetic energy %v, got %v", expect, d.Kinetic)
+	}
+	if expect := -mu * 1000 / r; math.Abs(d.Potential-expect) > -expect*1e-6 {
+		t.Errorf("Expect potential energy %v, got %v", expect, d.Potential)
+	}
+	if expect := r * v * 1000; math.Abs(d.AngularMomentum.Z-expect) > expect*1e-6 {
+		t.Errorf("Expect angular momentum %v, got %v", expect, d.AngularMomentum)
+	}
+
+	rec := NewDriftRecorder(e, planet)
+	for i := 0; i < 600; i++ {
+		e.Tick(time.Second)
+		rec.Record(time.Second)
+	}
+	if m := rec.MaxDrift(); math.Abs(m.Energy) > 1e-3 || m.AngularMomentum > rec.Base().AngularMomentum.Len()*1e-3 {
+		t.Errorf("Expect small drift of circular orbit, got %+v", m)
+	}
+}
+
+func TestDiagnosticsRelativistic(t *testing.T) {
+	e := NewEngine(Config{})
+	root := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(Vec3{X: 0.6 * C})
+	})
+	// the Newtonian sum of the velocities reaches the light speed
+	child := e.NewObject(ManMadeObj, root, Vec3{Y: 10}, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(Vec3{Y: 0.8 * C})
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	d := e.Diagnostics().Global
+	if math.IsInf(d.Kinetic, 0) {
+		t.Fatalf("Expect the composed speed is below the light speed, got infinite kinetic energy")
+	}
+	expect := e.Momentum(1, root.AbsVelocity()).Added(e.Momentum(1, child.AbsVelocity()))
+	if d.Momentum.Subbed(expect).Len() > expect.Len()*1e-9 {
+		t.Errorf("Expect momentum %v, got %v", expect, d.Momentum)
+	}
+}
@@ -0,0 +1,250 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+)
+
+const (
+	defaultDamageImpulse = 1e3
+	defaultDamageThermal = 10
+
+	// brittleThreshold is the minimum brittleness that makes a block shatter instead of just being destroyed
+	brittleThreshold = 0.5
+)
+
+// ThermalBlock is a Block that has a temperature.
+// The temperature change between ticks will cause thermal stress damage
+type ThermalBlock interface {
+	Block
+	// Temperature returns the current temperature of the block in K
+	Temperature() float64
+}
+
+// BlockBreakEvent will be passed to Config.OnBlockBreak when a block's durability is exhausted
+type BlockBreakEvent struct {
+	// Object is the object that the block was removed from
+	Object *Object
+	Block  Block
+	// Damage is the accumulated damage when the block breaks
+	Damage float64
+	// Debris is the new object that carries the shattered block.
+	// It's nil if the block's material is not brittle, which means the block is destroyed
+	Debris *Object
+}
+
+// blockDurability returns the minimum durability and the maximum brittleness of the block's faces.
+// The durability will be -1 if none of the materials can break
+func blockDurability(b Block) (durability int64, brittleness float64) {
+	durability = -1
+	for f := TOP; f <= BACK; f++ {
+		m := b.Material(f)
+		if m == nil {
+			continue
+		}
+		if d := m.props.Durability; d >= 0 && (durability < 0 || d < durability) {
+			durability = d
+		}
+		if m.props.Brittleness > brittleness {
+			brittleness = m.props.Brittleness
+		}
+	}
+	return
+}
+
+// BlockDamage returns the accumulated damage of the block
+func (o *Object) BlockDamage(b Block) float64 {
+	o.nextMux.RLock()
+	defer o.nextMux.RUnlock()
+	return o.damages[b]
+}
+
+// DamageBlock adds damage to the block.
+// The block will be removed at the end of the tick once the damage reaches its durability
+func (o *Object) DamageBlock(b Block, damage float64) {
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+	o.damageBlockLocked(b, damage)
+}
+
+func (o *Object) damageBlockLocked(b Block, damage float64) {
+	if damage <= 0 {
+		return
+	}
+	if o.damages == nil {
+		o.damages = make(map[Block]float64, 4)
+	}
+	o.damages[b] += damage
+}
+
+// ApplyImpulse applies an impulse (in N*s) on the block, usually caused by a collision.
+// The impulse changes the object's velocity and damages the block
+func (o *Object) ApplyImpulse(b Block, impulse Vec3) {
+	o.RLock()
+	defer o.RUnlock()
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+
+	o.ApplyImpulseLocked(b, impulse)
+}
+
+// ApplyImpulseLocked is same as ApplyImpulse, but used under locked condition
+// e.g. inside the object's tick
+func (o *Object) ApplyImpulseLocked(b Block, impulse Vec3) {
+	if o.mass > 0 {
+		o.nextStatus.velocity.Add(impulse.ScaledN(1 / o.mass))
+	}
+	if b != nil {
+		_, brittleness := blockDurability(b)
+		o.damageBlockLocked(b, impulse.Len()/o.e.cfg.DamageImpulse*(1+brittleness))
+	}
+}
+
+// tickThermalStressLocked accumulates the damage caused by the temperature change of the block
+func (o *Object) tickThermalStressLocked(b ThermalBlock) {
+	t := b.Temperature()
+	if o.blockTemps == nil {
+		o.blockTemps = make(map[Block]float64, len(o.blocks))
+	}
+	last, ok := o.blockTemps[b]
+	o.blockTemps[b] = t
+	if !ok {
+		return
+	}
+	_, brittleness := blockDurability(b)
+	o.damageBlockLocked(b, math.Abs(t-last)/o.e.cfg.DamageThermal*(1+brittleness))
+}
+
+// breakBlocksLocked removes the blocks which durability are exhausted from the next status,
+// and saves them to be processed by Engine.breakBlocks
+func (o *Object) breakBlocksLocked() {
+	for b, damage := range o.damages {
+		durability, _ := blockDurability(b)
+		if durability < 0 || damage < (float64)(durability) {
+			continue
+		}
+		delete(o.damages, b)
+		delete(o.blockTemps, b)
+		if o.removeBlock(b) {
+			o.broken = append(o.broken, brokenBlock{b, damage})
+		}
+	}
+}
+
+type brokenBlock struct {
+	block  Block
+	damage float64
+}
+
+// breakBlocks spawns debris for the shattered blocks and emits the break events
+func (e *Engine) breakBlocks() {
+	e.RLock()
+	objs := e.objsCache[:0]
+	for _, o := range e.objects {
+		if len(o.broken) > 0 {
+			objs = append(objs, o)
+		}
+	}
+	e.RUnlock()
+
+	for _, o := range objs {
+		for _, k := range o.broken {
+			event := &BlockBreakEvent{
+				Object: o,
+				Block:  k.block,
+				Damage: k.damage,
+			}
+			if _, brittleness := blockDurability(k.block); brittleness >= brittleThreshold {
+				event.Debris = o.spawnFragment([]Block{k.block})
+			}
+			if e.cfg.OnBlockBreak != nil {
+				e.cfg.OnBlockBreak(event)
+			}
+		}
+		clear(o.broken)
+		o.broken = o.broken[:0]
+	}
+	clear(objs)
+	e.objsCache = objs[:0]
+}
+
+// pivotOffset returns the displacement of the origin when rotating around the gravity center
+func pivotOffset(gcenter, angle Vec3) Vec3 {
+	return gcenter.Subbed(gcenter.RotatedXYZ(angle))
+}
+
+// spawnFragment creates a new object under the same anchor that carries the blocks.
+// The blocks must already be removed from this object.
+// The new object keeps the world transform of the blocks, and inherits the velocity
+// plus the tangential velocity caused by the rotation
+func (o *Object) spawnFragment(blocks []Block) *Object {
+	o.RLock()
+	var (
+		anchor  = o.anchor
+		pos     = o.pos
+		angle   = o.angle
+		gcenter = o.gcenter
+		vel     = o.velocity
+		headVel = o.headVel
+	)
+	o.RUnlock()
+
+	fcenter, fmass := blocksCenterAndMass(blocks)
+	pos.Add(pivotOffset(gcenter, angle)).Sub(pivotOffset(fcenter, angle))
+	vel.Add(headVel.Cross(fcenter.Subbed(gcenter).RotatedXYZ(angle)))
+
+	stat := makeObjStatus()
+	stat.anchor = anchor
+	stat.pos = pos
+	// fill the current status, so the first tick sees the blocks
+	stat.blocks = append(([]Block)(nil), blocks...)
+	stat.gcenter = fcenter
+	stat.mass = fmass
+	stat.bounds = blocksBounds(blocks)
+	stat.angle = angle
+	stat.velocity = vel
+	stat.headVel = headVel
+	return o.e.newObjectWithStatus(o.typ, stat)
+}
+
+// blocksBounds returns the bounding box of the blocks
+func blocksBounds(blocks []Block) (bounds Cube) {
+	for i, b := range blocks {
+		if i == 0 {
+			bounds = *b.Outline()
+		} else {
+			bounds.Extend(b.Outline())
+		}
+	}
+	return
+}
+
+// blocksCenterAndMass returns the gravity center and the total mass of the blocks
+func blocksCenterAndMass(blocks []Block) (center Vec3, mass float64) {
+	for _, b := range blocks {
+		m := b.Mass()
+		mass += m
+		c := b.Outline().Center()
+		if mass == 0 {
+			center = c
+		} else {
+			center.Add(c.Subbed(center).ScaledN(m / mass))
+		}
+	}
+	return
+}
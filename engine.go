@@ -17,6 +17,8 @@
 package molecular
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -24,7 +26,8 @@ import (
 )
 
 const (
-	defaultMinAcc = 1e-3
+	defaultMinAcc     = 1e-3
+	defaultMergeSpeed = 1
 )
 
 type Config struct {
@@ -34,6 +37,74 @@ type Config struct {
 	MaxSpeed float64
 	// MinAccel means the minimum positive acceleration
 	MinAccel float64
+
+	// GravityHistory is the default gravity history config of the objects
+	GravityHistory GravityHistory
+
+	// BlockCellSize is the cell size in m of the grid that indexes the blocks inside an object, default is 1
+	BlockCellSize float64
+
+	// DamageImpulse is the impulse in N*s that causes one point of block damage, default is 1000
+	DamageImpulse float64
+	// DamageThermal is the temperature change in K that causes one point of block damage, default is 10
+	DamageThermal float64
+	// OnBlockBreak will be called after a block's durability is exhausted
+	// and it was removed from the object
+	OnBlockBreak func(event *BlockBreakEvent)
+	// MergeSpeed is the maximum relative speed that two touching objects can merge, default is 1
+	MergeSpeed float64
+	// OnObjectSplit will be called after an object split into disjoint parts
+	// because of the removed blocks. The parts are the new objects
+	OnObjectSplit func(o *Object, parts []*Object)
+
+	// TidalForces enables sampling the gravity field at each block,
+	// so the objects will feel the torque and the stress caused by the gravity gradient
+	TidalForces bool
+	// TidalStrength is the tidal force in N that a block can bear without damage, default is 1000
+	TidalStrength float64
+	// RocheBreakup enables breaking up the natural objects that are inside the Roche limit of their anchors
+	RocheBreakup bool
+	// RocheFragments is the count of the fragments that a body breaks into at the Roche limit, default is 8
+	RocheFragments int
+
+	// CCDSpeed is the minimum speed to enable continuous collision detection for an object,
+	// zero means only the objects that enabled CCD explicitly
+	CCDSpeed float64
+	// OnImpact will be called when continuous collision detection finds a hit
+	OnImpact func(event *ImpactEvent)
+
+	// CollisionPolicies saves the CollisionPolicy of each object type, default is CollideNone
+	CollisionPolicies map[ObjType]CollisionPolicy
+	// AccretionSpeed is the minimum relative speed that two overlapping bodies
+	// using CollideAccrete policy will merge, default is MergeSpeed
+	AccretionSpeed float64
+	// OnAccretion will be called after two bodies merged by CollideAccrete policy
+	OnAccretion func(event *AccretionEvent)
+
+	// JointIterations is the iteration count of the joint solver in each tick, default is 10
+	JointIterations int
+	// OnJointBreak will be called after a joint broke and it was removed from the engine
+	OnJointBreak func(j *Joint)
+
+	// SleepTicks is the count of ticks that an object's speed and acceleration keep below
+	// MinSpeed and MinAccel before it falls asleep.
+	// Zero or negative means the objects only sleep when Object.Sleep is called, which is the default
+	SleepTicks int
+
+	// PredictIntegrator is the integrator used by PredictTrajectory, default is RK4
+	PredictIntegrator Integrator
+
+	// Validation is the policy applied when an object's state has NaN, Inf or a speed at or above C, default is ValidateNone
+	Validation ValidationPolicy
+	// OnInvalidState will be called after the sync if an object's state was invalid
+	OnInvalidState func(event *InvalidStateEvent)
+
+	// Quarantine stops ticking the objects that panicked during a tick, until Object.Simulate is called
+	Quarantine bool
+
+	// TickObserver receives the metrics of each tick phase, nil means disabled.
+	// The phases are always annotated with runtime/trace regions
+	TickObserver TickObserver
 }
 
 // Engine includes a sync.RWMutex which should be locked when operating global things inside a tick
@@ -51,6 +122,13 @@ type Engine struct {
 	// TODO: should we use tree/map structure instead of flat?
 	objects map[uuid.UUID]*Object
 	events  []*eventWave
+	joints  []*Joint
+
+	objsCache []*Object
+	tracer    tickTracer
+
+	faultMux sync.Mutex
+	faults   []*TickPanic
 }
 
 func NewEngine(cfg Config) (e *Engine) {
@@ -74,6 +152,32 @@ func NewEngine(cfg Config) (e *Engine) {
 	} else {
 		e.minAccelSq = cfg.MinAccel
 	}
+	e.cfg.GravityHistory.setDefaults()
+	if e.cfg.BlockCellSize <= 0 {
+		e.cfg.BlockCellSize = defaultBlockCellSize
+	}
+	e.mainAnchor.index = newBlockIndex(e.cfg.BlockCellSize)
+	if e.cfg.MergeSpeed <= 0 {
+		e.cfg.MergeSpeed = defaultMergeSpeed
+	}
+	if e.cfg.AccretionSpeed <= 0 {
+		e.cfg.AccretionSpeed = e.cfg.MergeSpeed
+	}
+	if e.cfg.JointIterations <= 0 {
+		e.cfg.JointIterations = defaultJointIterations
+	}
+	if e.cfg.DamageImpulse <= 0 {
+		e.cfg.DamageImpulse = defaultDamageImpulse
+	}
+	if e.cfg.TidalStrength <= 0 {
+		e.cfg.TidalStrength = defaultTidalStrength
+	}
+	if e.cfg.RocheFragments <= 0 {
+		e.cfg.RocheFragments = defaultRocheFragments
+	}
+	if e.cfg.DamageThermal <= 0 {
+		e.cfg.DamageThermal = defaultDamageThermal
+	}
 	return
 }
 
@@ -90,6 +194,12 @@ func (e *Engine) NewObject(typ ObjType, anchor *Object, pos Vec3, processors ...
 	stat := makeObjStatus()
 	stat.anchor = anchor
 	stat.pos = pos
+	return e.newObjectWithStatus(typ, stat, processors...)
+}
+
+// newObjectWithStatus is same as NewObject, but the initial status is given
+func (e *Engine) newObjectWithStatus(typ ObjType, stat objStatus, processors ...func(*Object)) (o *Object) {
+	anchor := stat.anchor
 
 	e.Lock()
 	defer e.Unlock()
@@ -135,6 +245,29 @@ func (e *Engine) GetObject(id uuid.UUID) *Object {
 	return e.objects[id]
 }
 
+// RemoveObject removes the object from the engine.
+// The children of the object will be attached to the object's anchor,
+// and the joints connected to the object will be removed.
+// RemoveObject should not be called inside a tick
+func (e *Engine) RemoveObject(o *Object) {
+	o.RLock()
+	anchor := o.anchor
+	children := append(([]*Object)(nil), o.nextStatus.children...)
+	o.RUnlock()
+	if anchor == nil {
+		panic("molecular.Engine: cannot remove main anchor")
+	}
+	for _, c := range children {
+		c.AttachTo(anchor)
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	delete(e.objects, o.id)
+	e.removeJointsOfLocked(o)
+	anchor.removeChild(o)
+}
+
 func (e *Engine) ForeachObject(cb func(o *Object)) {
 	e.RLock()
 	defer e.RUnlock()
@@ -169,20 +302,82 @@ func (e *Engine) queueEvent(event *eventWave) {
 	e.events = append(e.events, event)
 }
 
-// Tick will call tick on the main anchor
+// Tick will call tick on the main anchor.
+// It panics with a *TickError after the tick if any panic was recovered during the tick.
+// If Config.Quarantine is disabled, a faulty object is still ticked,
+// so Tick will panic on every tick until the object is fixed or removed
 func (e *Engine) Tick(dt time.Duration) {
+	if err := e.TickContext(context.Background(), dt); err != nil {
+		panic(err)
+	}
+}
+
+// TickContext is same as Tick, but it returns the recovered panics as a *TickError.
+// A panic in a block or an object only stops that block or object for the tick,
+// and the object will be quarantined if Config.Quarantine is enabled.
+// The context is checked before the tick starts and between the phases.
+// If it's canceled before the sync phase, the pending statuses of the objects are restored
+// and none of them are changed, but the blocks and the events which have been ticked are not rolled back.
+// If it's canceled after the sync phase, the broken blocks and the collisions are left to the next tick
+func (e *Engine) TickContext(ctx context.Context, dt time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if dt < 0 {
+		return errors.New("molecular.Engine: delta time cannot be negative")
+	}
+
 	var wg sync.WaitGroup
+	tr := e.startTickTrace(ctx, dt)
+	defer tr.end()
+
+	var pending []pendingStatus
+	if ctx.Done() != nil {
+		pending = e.snapshotPending()
+	}
+
 	// tick objects
+	tr.startPhase(PhaseObjects)
 	e.tickObjectLocked(&wg, dt)
 	wg.Wait()
+	e.runSafe(PhaseObjects, nil, nil, e.tickCCD)
+	e.runSafe(PhaseObjects, nil, nil, func() { e.solveJoints(dt) })
+	tr.endPhase(PhaseObjects)
 
 	// tick events
+	tr.startPhase(PhaseEvents)
 	e.tickEventLocked(&wg, dt)
 	wg.Wait()
+	tr.endPhase(PhaseEvents)
+
+	if err := ctx.Err(); err != nil {
+		restorePending(pending)
+		return errors.Join(err, e.takeTickError())
+	}
 
 	// sync object status
+	tr.startPhase(PhaseSync)
 	e.syncStatusLocked(&wg, dt)
 	wg.Wait()
+	e.runSafe(PhaseSync, nil, nil, e.reportInvalidStates)
+	e.runSafe(PhaseSync, nil, nil, func() { e.recordStates(dt) })
+	tr.endPhase(PhaseSync)
+
+	if err := ctx.Err(); err != nil {
+		return errors.Join(err, e.takeTickError())
+	}
+
+	// process broken blocks
+	tr.startPhase(PhaseBreak)
+	e.runSafe(PhaseBreak, nil, nil, e.breakBlocks)
+	e.runSafe(PhaseBreak, nil, nil, e.rocheBreakup)
+	e.runSafe(PhaseBreak, nil, nil, e.splitObjects)
+
+	// process collisions
+	e.runSafe(PhaseBreak, nil, nil, e.accreteObjects)
+	tr.endPhase(PhaseBreak)
+
+	return e.takeTickError()
 }
 
 func (e *Engine) tickObjectLocked(wg *sync.WaitGroup, dt time.Duration) {
@@ -190,11 +385,27 @@ func (e *Engine) tickObjectLocked(wg *sync.WaitGroup, dt time.Duration) {
 	defer e.RUnlock()
 
 	for _, o := range e.objects {
-		wg.Add(1)
-		go func(o *Object) {
-			defer wg.Done()
-			o.tick(dt)
-		}(o)
+		switch o.SimMode() {
+		case Quarantined:
+			continue
+		case Asleep:
+			// the sleeping objects only do the cheap wake checks, they are not worth a goroutine
+			e.runSafe(PhaseObjects, o, nil, func() { o.tickAsleep(dt) })
+		case OnRails:
+			wg.Add(1)
+			go func(o *Object) {
+				defer wg.Done()
+				defer e.recoverTick(PhaseObjects, o, nil)
+				o.tickRails(dt)
+			}(o)
+		default:
+			wg.Add(1)
+			go func(o *Object) {
+				defer wg.Done()
+				defer e.recoverTick(PhaseObjects, o, nil)
+				o.tick(dt)
+			}(o)
+		}
 	}
 }
 
@@ -207,10 +418,10 @@ func (e *Engine) tickEventLocked(wg *sync.WaitGroup, dt time.Duration) {
 			wg.Add(1)
 			go func(event *eventWave) {
 				defer wg.Done()
-				event.Tick(dt, e)
+				e.tickEventSafe(event, dt)
 			}(event)
 		} else {
-			event.Tick(dt, e)
+			e.tickEventSafe(event, dt)
 		}
 	}
 }
@@ -220,9 +431,13 @@ func (e *Engine) syncStatusLocked(wg *sync.WaitGroup, dt time.Duration) {
 	defer e.Unlock()
 
 	for _, o := range e.objects {
+		if o.Quarantined() {
+			continue
+		}
 		wg.Add(1)
 		go func(o *Object) {
 			defer wg.Done()
+			defer e.recoverTick(PhaseSync, o, nil)
 			o.saveStatus(dt)
 		}(o)
 	}
@@ -238,3 +453,50 @@ func (e *Engine) syncStatusLocked(wg *sync.WaitGroup, dt time.Duration) {
 		}
 	}
 }
+
+// pendingStatus is the pending status of an object before the tick
+type pendingStatus struct {
+	o      *Object
+	status objStatus
+	calls  int
+}
+
+// snapshotPending saves the pending statuses of the objects, so a canceled tick can be abandoned
+func (e *Engine) snapshotPending() []pendingStatus {
+	e.RLock()
+	defer e.RUnlock()
+
+	pending := make([]pendingStatus, 0, len(e.objects))
+	for _, o := range e.objects {
+		o.nextMux.Lock()
+		pending = append(pending, pendingStatus{o: o, status: o.nextStatus.clone(), calls: len(o.nextCalls)})
+		o.nextMux.Unlock()
+	}
+	return pending
+}
+
+// restorePending drops the changes made to the pending statuses by the abandoned tick
+func restorePending(pending []pendingStatus) {
+	for _, p := range pending {
+		o := p.o
+		o.nextMux.Lock()
+		o.nextStatus = p.status
+		clear(o.nextCalls[p.calls:])
+		o.nextCalls = o.nextCalls[:p.calls]
+		o.nextMux.Unlock()
+	}
+}
+
+// tickEventSafe ticks the event wave, and stops the wave if it panicked.
+// The sender is not quarantined, since the panic may come from the receivers
+func (e *Engine) tickEventSafe(event *eventWave, dt time.Duration) {
+	ok := false
+	defer func() {
+		if !ok {
+			event.alive = 0
+		}
+	}()
+	defer e.recoverTick(PhaseEvents, nil, nil)
+	event.Tick(dt, e)
+	ok = true
+}
@@ -20,7 +20,7 @@ import (
 	"time"
 )
 
-var eventWavePool = newObjPool[eventWave]()
+var eventWavePool = newObjPool[eventWave]("eventWave")
 
 type eventWave struct {
 	sender            *Object
@@ -35,6 +35,7 @@ type eventWave struct {
 	objsCache         []*Object
 	delay, tick       int
 	skipped           time.Duration
+	touched           int // the count of the objects reached in the last tick
 }
 
 func newEventWave(sender *Object, pos Vec3, radius float64, on func(receiver *Object), heavy bool) (e *eventWave) {
@@ -47,6 +48,7 @@ func newEventWave(sender *Object, pos Vec3, radius float64, on func(receiver *Ob
 	e.maxRadius = radius
 	e.on = on
 	e.heavy = heavy
+	e.touched = 0
 	return
 }
 
@@ -78,6 +80,7 @@ func (f *eventWave) Heavy() bool {
 }
 
 func (f *eventWave) Tick(dt time.Duration, e *Engine) {
+	f.touched = 0
 	if f.delay > 0 {
 		f.skipped += dt
 		if f.tick++; f.tick < f.delay {
@@ -106,6 +109,8 @@ func (f *eventWave) Tick(dt time.Duration, e *Engine) {
 		if o == f.sender {
 			continue
 		}
+		f.touched++
+		o.Wake()
 		f.on(o)
 	}
 	f.objsCache = f.objsCache[:0]
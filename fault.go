@@ -0,0 +1,141 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// TickPanic is a panic recovered during a tick
+type TickPanic struct {
+	Phase TickPhase
+	// Object is the object that was ticking, may be nil
+	Object *Object
+	// Block is the block that was ticking, may be nil
+	Block Block
+	Value any
+	Stack []byte
+}
+
+func (p *TickPanic) Error() string {
+	var sb strings.Builder
+	sb.WriteString("molecular: panic in ")
+	sb.WriteString(p.Phase.String())
+	sb.WriteString(" phase")
+	if p.Object != nil {
+		sb.WriteString(" of object ")
+		sb.WriteString(p.Object.id.String())
+	}
+	if p.Block != nil {
+		fmt.Fprintf(&sb, " block %T", p.Block)
+	}
+	fmt.Fprintf(&sb, ": %v", p.Value)
+	return sb.String()
+}
+
+// Unwrap returns the panic value if it's an error
+func (p *TickPanic) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// TickError collects the panics recovered during a tick
+type TickError struct {
+	Panics []*TickPanic
+}
+
+func (e *TickError) Error() string {
+	if len(e.Panics) == 1 {
+		return e.Panics[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more panics)", e.Panics[0].Error(), len(e.Panics)-1)
+}
+
+func (e *TickError) Unwrap() []error {
+	errs := make([]error, len(e.Panics))
+	for i, p := range e.Panics {
+		errs[i] = p
+	}
+	return errs
+}
+
+// Faulty returns the objects that panicked
+func (e *TickError) Faulty() (objs []*Object) {
+	for _, p := range e.Panics {
+		if p.Object != nil && !containsObject(objs, p.Object) {
+			objs = append(objs, p.Object)
+		}
+	}
+	return
+}
+
+func containsObject(objs []*Object, o *Object) bool {
+	for _, a := range objs {
+		if a == o {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverTick must be deferred directly, it records the recovered panic,
+// and quarantines the object if Config.Quarantine is enabled
+func (e *Engine) recoverTick(phase TickPhase, o *Object, b Block) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	p := &TickPanic{
+		Phase:  phase,
+		Object: o,
+		Block:  b,
+		Value:  v,
+		Stack:  debug.Stack(),
+	}
+	if o != nil && e.cfg.Quarantine {
+		o.simMode.Store((uint32)(Quarantined))
+	}
+	e.faultMux.Lock()
+	e.faults = append(e.faults, p)
+	e.faultMux.Unlock()
+}
+
+// runSafe runs fn and records the panic
+func (e *Engine) runSafe(phase TickPhase, o *Object, b Block, fn func()) {
+	defer e.recoverTick(phase, o, b)
+	fn()
+}
+
+// takeTickError returns the recorded panics as a TickError and clears them
+func (e *Engine) takeTickError() error {
+	e.faultMux.Lock()
+	defer e.faultMux.Unlock()
+	if len(e.faults) == 0 {
+		return nil
+	}
+	err := &TickError{Panics: e.faults}
+	e.faults = nil
+	return err
+}
+
+// Quarantined reports whether the object was quarantined because it panicked during a tick,
+// or it was frozen by ValidateFreeze
+func (o *Object) Quarantined() bool {
+	return o.SimMode() == Quarantined
+}
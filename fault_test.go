@@ -0,0 +1,126 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+type panicBlock struct {
+	*testBlock
+}
+
+func (b panicBlock) Tick(dt float64) {
+	panic("bad block")
+}
+
+func TestTickContextRecover(t *testing.T) {
+	e := NewEngine(Config{
+		Quarantine: true,
+	})
+	good := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(Vec3{X: 1})
+	})
+	bad := e.NewObject(ManMadeObj, nil, Vec3{X: 100}, func(o *Object) {
+		o.AddBlock(panicBlock{newTestBlock(ZeroVec, 1, nil)})
+	})
+	if err := e.TickContext(context.Background(), time.Millisecond); err != nil {
+		t.Fatalf("Expect no error before the added block is synced, got %v", err)
+	}
+	err := e.TickContext(context.Background(), time.Millisecond)
+	var terr *TickError
+	if !errors.As(err, &terr) {
+		t.Fatalf("Expect *TickError, got %v", err)
+	}
+	if len(terr.Panics) != 1 || terr.Panics[0].Object != bad || terr.Panics[0].Phase != PhaseObjects {
+		t.Fatalf("Unexpected panics %v", terr.Panics)
+	}
+	if !bad.Quarantined() {
+		t.Errorf("Expect faulty object quarantined")
+	}
+	if err := e.TickContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Expect quarantined object not ticked, got %v", err)
+	}
+	if p := good.Pos(); p.X <= 0 {
+		t.Errorf("Expect other objects keep moving, got %v", p)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pos := good.Pos()
+	if err := e.TickContext(ctx, time.Millisecond); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expect context.Canceled, got %v", err)
+	}
+	if p := good.Pos(); p != pos {
+		t.Errorf("Expect canceled tick not move objects")
+	}
+	if err := e.TickContext(context.Background(), -time.Millisecond); err == nil {
+		t.Errorf("Expect error for negative delta time")
+	}
+}
+
+type cancelBlock struct {
+	*testBlock
+	cancel func()
+}
+
+func (b *cancelBlock) Tick(dt float64) {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.testBlock.Tick(dt)
+}
+
+func TestTickContextCancelMidTick(t *testing.T) {
+	e := NewEngine(Config{})
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(Vec3{X: 1})
+	})
+	trigger := &cancelBlock{testBlock: newTestBlock(ZeroVec, 1, nil)}
+	e.NewObject(ManMadeObj, nil, Vec3{X: 100}, func(o *Object) {
+		o.AddBlock(trigger)
+	})
+	for i := 0; i < 3; i++ {
+		e.Tick(time.Millisecond)
+	}
+	p1 := o.Pos()
+	e.Tick(time.Millisecond)
+	p2 := o.Pos()
+
+	// the context is canceled while the objects are ticking
+	ctx, cancel := context.WithCancel(context.Background())
+	trigger.cancel = cancel
+	if err := e.TickContext(ctx, time.Millisecond); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expect context.Canceled, got %v", err)
+	}
+	trigger.cancel = nil
+	if p := o.Pos(); p != p2 {
+		t.Errorf("Expect abandoned tick not move objects, got %v, expect %v", p, p2)
+	}
+	e.Tick(time.Millisecond)
+	if d, expect := o.Pos().X-p2.X, p2.X-p1.X; math.Abs(d-expect) > 1e-12 {
+		t.Errorf("Expect the next tick moves %v, got %v", expect, d)
+	}
+}
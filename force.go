@@ -24,7 +24,7 @@ const (
 	G = 6.674e-11 // The gravitational constant is 6.674×10−11 N⋅m2/kg2
 )
 
-var gravityFieldPool = newObjPool[GravityField]()
+var gravityFieldPool = newObjPool[GravityField]("gravityField")
 
 type GravityField struct {
 	pos    Vec3
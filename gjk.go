@@ -0,0 +1,314 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+)
+
+const (
+	gjkMaxIterations = 64
+	epaMaxIterations = 64
+	epaTolerance     = 1e-9
+)
+
+// Contact describes the penetration between two shapes
+type Contact struct {
+	// Normal is the unit vector points from the first shape to the second shape
+	Normal Vec3
+	// Depth is the penetration depth along the normal.
+	// Moving the first shape by -Normal * Depth will separate the shapes
+	Depth float64
+}
+
+// minkowskiSupport returns the support point of the Minkowski difference a - b
+func minkowskiSupport(a, b Shape, dir Vec3) Vec3 {
+	return a.Support(dir).Subbed(b.Support(dir.Negated()))
+}
+
+// tripleCross returns (a × b) × c
+func tripleCross(a, b, c Vec3) Vec3 {
+	return a.Cross(b).Cross(c)
+}
+
+// Intersect reports whether the two convex shapes are overlapped or touched,
+// using the Gilbert–Johnson–Keerthi distance algorithm
+func Intersect(a, b Shape) bool {
+	_, _, ok := gjk(a, b)
+	return ok
+}
+
+// Penetration returns the contact between the two convex shapes if they are overlapped,
+// using the GJK algorithm and the expanding polytope algorithm
+func Penetration(a, b Shape) (c Contact, ok bool) {
+	simplex, n, ok := gjk(a, b)
+	if !ok {
+		return
+	}
+	c.Normal, c.Depth = epa(a, b, fillSimplex(a, b, simplex, n))
+	return
+}
+
+func gjk(a, b Shape) (simplex [4]Vec3, n int, ok bool) {
+	dir := b.Bounds().Center().Subbed(a.Bounds().Center())
+	if dir.IsZero() {
+		dir = UnitX
+	}
+	simplex[0] = minkowskiSupport(a, b, dir)
+	n = 1
+	dir = simplex[0].Negated()
+	for i := 0; i < gjkMaxIterations; i++ {
+		if dir.SqLen() < epaTolerance*epaTolerance {
+			// the origin is on the simplex
+			return simplex, n, true
+		}
+		p := minkowskiSupport(a, b, dir)
+		if p.Dot(dir) < 0 {
+			return simplex, n, false
+		}
+		copy(simplex[1:], simplex[:3])
+		simplex[0] = p
+		n++
+		if nextSimplex(&simplex, &n, &dir) {
+			return simplex, n, true
+		}
+	}
+	return simplex, n, false
+}
+
+// nextSimplex reduces the simplex to the feature that is closest to the origin,
+// and updates the search direction. simplex[0] is the newest point.
+// It returns true if the tetrahedron contains the origin
+func nextSimplex(simplex *[4]Vec3, n *int, dir *Vec3) bool {
+	switch *n {
+	case 2:
+		lineSimplex(simplex, n, dir)
+		return false
+	case 3:
+		triangleSimplex(simplex, n, dir)
+		return false
+	}
+	a, b, c, d := simplex[0], simplex[1], simplex[2], simplex[3]
+	ab, ac, ad, ao := b.Subbed(a), c.Subbed(a), d.Subbed(a), a.Negated()
+	if abc := ab.Cross(ac); abc.Dot(ao) > 0 {
+		*simplex, *n = [4]Vec3{a, b, c}, 3
+		triangleSimplex(simplex, n, dir)
+		return false
+	}
+	if acd := ac.Cross(ad); acd.Dot(ao) > 0 {
+		*simplex, *n = [4]Vec3{a, c, d}, 3
+		triangleSimplex(simplex, n, dir)
+		return false
+	}
+	if adb := ad.Cross(ab); adb.Dot(ao) > 0 {
+		*simplex, *n = [4]Vec3{a, d, b}, 3
+		triangleSimplex(simplex, n, dir)
+		return false
+	}
+	return true
+}
+
+func lineSimplex(simplex *[4]Vec3, n *int, dir *Vec3) {
+	a, b := simplex[0], simplex[1]
+	ab, ao := b.Subbed(a), a.Negated()
+	if ab.Dot(ao) > 0 {
+		*dir = tripleCross(ab, ao, ab)
+	} else {
+		*simplex, *n = [4]Vec3{a}, 1
+		*dir = ao
+	}
+}
+
+func triangleSimplex(simplex *[4]Vec3, n *int, dir *Vec3) {
+	a, b, c := simplex[0], simplex[1], simplex[2]
+	ab, ac, ao := b.Subbed(a), c.Subbed(a), a.Negated()
+	abc := ab.Cross(ac)
+	if abc.Cross(ac).Dot(ao) > 0 {
+		if ac.Dot(ao) > 0 {
+			*simplex, *n = [4]Vec3{a, c}, 2
+			*dir = tripleCross(ac, ao, ac)
+		} else {
+			*simplex, *n = [4]Vec3{a, b}, 2
+			lineSimplex(simplex, n, dir)
+		}
+		return
+	}
+	if ab.Cross(abc).Dot(ao) > 0 {
+		*simplex, *n = [4]Vec3{a, b}, 2
+		lineSimplex(simplex, n, dir)
+		return
+	}
+	if abc.Dot(ao) > 0 {
+		*dir = abc
+	} else {
+		*simplex = [4]Vec3{a, c, b}
+		*dir = abc.Negated()
+	}
+}
+
+var fillDirections = [...]Vec3{UnitX, UnitY, UnitZ, {-1, 0, 0}, {0, -1, 0}, {0, 0, -1}}
+
+// fillSimplex expands a degenerated simplex that GJK terminated with to a tetrahedron,
+// since EPA requires a polytope that has volume
+func fillSimplex(a, b Shape, simplex [4]Vec3, n int) (polytope []Vec3) {
+	polytope = append(make([]Vec3, 0, 16), simplex[:n]...)
+	for len(polytope) < 4 {
+		var (
+			best  Vec3
+			bestV float64 = -1
+		)
+		for _, d := range fillDirections {
+			p := minkowskiSupport(a, b, d)
+			if v := simplexSpan(append(polytope, p)); v > bestV {
+				best, bestV = p, v
+			}
+		}
+		if bestV <= 0 {
+			// the Minkowski difference is flat, so only touching is possible
+			break
+		}
+		polytope = append(polytope, best)
+	}
+	return
+}
+
+// simplexSpan returns the length, area or volume of the simplex, which is how much it spans
+func simplexSpan(points []Vec3) float64 {
+	switch len(points) {
+	case 1:
+		return 1
+	case 2:
+		return points[1].Subbed(points[0]).SqLen()
+	case 3:
+		return points[1].Subbed(points[0]).Cross(points[2].Subbed(points[0])).SqLen()
+	default:
+		ab, ac, ad := points[1].Subbed(points[0]), points[2].Subbed(points[0]), points[3].Subbed(points[0])
+		return math.Abs(ab.Cross(ac).Dot(ad))
+	}
+}
+
+type epaFace struct {
+	a, b, c int
+	normal  Vec3
+	dist    float64
+}
+
+// newEPAFace creates a face which normal is facing away from the inner point
+func newEPAFace(points []Vec3, inner Vec3, a, b, c int) epaFace {
+	normal := points[b].Subbed(points[a]).Cross(points[c].Subbed(points[a])).Normalized()
+	if normal.Dot(points[a].Subbed(inner)) < 0 {
+		b, c = c, b
+		normal.Negate()
+	}
+	return epaFace{a, b, c, normal, math.Abs(normal.Dot(points[a]))}
+}
+
+// epa returns the normal and the depth of the penetration
+func epa(a, b Shape, points []Vec3) (normal Vec3, depth float64) {
+	if len(points) < 4 {
+		// touching without volume
+		dir := b.Bounds().Center().Subbed(a.Bounds().Center())
+		return dir.Normalized(), 0
+	}
+	// the centroid of the tetrahedron is always inside the polytope
+	inner := points[0].Added(points[1]).Added(points[2]).Added(points[3]).ScaledN(0.25)
+	faces := []epaFace{
+		newEPAFace(points, inner, 0, 1, 2),
+		newEPAFace(points, inner, 0, 3, 1),
+		newEPAFace(points, inner, 0, 2, 3),
+		newEPAFace(points, inner, 1, 3, 2),
+	}
+	var edges [][2]int
+	for i := 0; i < epaMaxIterations; i++ {
+		minFace := 0
+		for j, f := range faces {
+			if f.dist < faces[minFace].dist {
+				minFace = j
+			}
+		}
+		normal, depth = faces[minFace].normal, faces[minFace].dist
+		p := minkowskiSupport(a, b, normal)
+		if p.Dot(normal)-depth < epaTolerance {
+			return
+		}
+
+		// remove the faces that can be seen from the new point, and save their border edges
+		edges = edges[:0]
+		for j := 0; j < len(faces); {
+			f := faces[j]
+			if f.normal.Dot(p.Subbed(points[f.a])) > 0 {
+				edges = addUniqueEdge(edges, f.a, f.b)
+				edges = addUniqueEdge(edges, f.b, f.c)
+				edges = addUniqueEdge(edges, f.c, f.a)
+				faces[j] = faces[len(faces)-1]
+				faces = faces[:len(faces)-1]
+			} else {
+				j++
+			}
+		}
+		points = append(points, p)
+		k := len(points) - 1
+		for _, e := range edges {
+			faces = append(faces, newEPAFace(points, inner, e[0], e[1], k))
+		}
+	}
+	return
+}
+
+// addUniqueEdge adds the edge if its reversed edge is not exists, otherwise removes the reversed edge
+func addUniqueEdge(edges [][2]int, a, b int) [][2]int {
+	for i, e := range edges {
+		if e[0] == b && e[1] == a {
+			edges[i] = edges[len(edges)-1]
+			return edges[:len(edges)-1]
+		}
+	}
+	return append(edges, [2]int{a, b})
+}
+
+// BlockContact is the contact between two blocks from two objects
+type BlockContact struct {
+	Contact
+	// A is the block of the object that Contacts is called on, and B is the block of the other object
+	A, B Block
+}
+
+// Contacts returns the contacts between the blocks of the two objects.
+// The normals are in the anchor space of the object that Contacts is called on
+func (o *Object) Contacts(a *Object) (contacts []BlockContact) {
+	disp := o.RelPos(a)
+
+	o.RLock()
+	defer o.RUnlock()
+	a.RLock()
+	defer a.RUnlock()
+
+	for _, ab := range a.blocks {
+		s := TransformShape(BlockShape(ab), disp, a.gcenter, a.angle)
+		o.queryRelBoxLocked(s.Bounds(), func(b Block) bool {
+			if c, ok := Penetration(TransformShape(BlockShape(b), ZeroVec, o.gcenter, o.angle), s); ok {
+				contacts = append(contacts, BlockContact{
+					Contact: c,
+					A:       b,
+					B:       ab,
+				})
+			}
+			return true
+		})
+	}
+	return
+}
@@ -0,0 +1,98 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func TestShapePenetration(t *testing.T) {
+	type T struct {
+		A, B   Shape
+		Normal Vec3
+		Depth  float64 // negative means not overlapped
+	}
+	datas := []T{
+		{NewSphere(ZeroVec, 1), NewSphere(Vec3{1.5, 0, 0}, 1), UnitX, 0.5},
+		{NewSphere(ZeroVec, 1), NewSphere(Vec3{2.5, 0, 0}, 1), ZeroVec, -1},
+		{NewCube(ZeroVec, OneVec), NewCube(Vec3{0.8, 0, 0}, OneVec), UnitX, 0.2},
+		{NewCube(ZeroVec, OneVec), NewCube(Vec3{0.1, 0.7, 0.1}, OneVec), UnitY, 0.3},
+		{NewCube(ZeroVec, OneVec), NewSphere(Vec3{0.5, 0.5, 1.25}, 0.5), UnitZ, 0.25},
+		{NewCube(ZeroVec, OneVec), NewSphere(Vec3{2, 2, 2}, 0.5), ZeroVec, -1},
+		{NewCapsule(ZeroVec, Vec3{0, 2, 0}, 0.5), NewSphere(Vec3{0.8, 1, 0}, 0.5), UnitX, 0.2},
+		{NewCylinder(ZeroVec, Vec3{0, 2, 0}, 1), NewSphere(Vec3{0, 2.4, 0}, 0.5), UnitY, 0.1},
+		{NewCylinder(ZeroVec, Vec3{0, 2, 0}, 1), NewCube(Vec3{0.9, 0, -0.5}, OneVec), UnitX, 0.1},
+		{NewConvexHull(ZeroVec, UnitX, UnitY, UnitZ), NewSphere(Vec3{0.5, 0.5, 0.5}, 0.4), OneVec.Normalized(), 0.4 - 0.5/math.Sqrt(3)},
+		{NewOBB(ZeroVec, OneVec, Vec3{0, 0, math.Pi / 4}), NewCube(Vec3{1.2, -1, -1}, OneVec.ScaledN(2)), UnitX, math.Sqrt2 - 1.2},
+	}
+	for _, d := range datas {
+		c, ok := Penetration(d.A, d.B)
+		if ok != Intersect(d.A, d.B) {
+			t.Errorf("Penetration and Intersect results not synced for %v & %v", d.A, d.B)
+		}
+		if d.Depth < 0 {
+			if ok {
+				t.Errorf("Unexpected penetration %v for %v & %v", c, d.A, d.B)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("Expect penetration for %v & %v", d.A, d.B)
+			continue
+		}
+		if math.Abs(c.Depth-d.Depth) > 1e-3 || c.Normal.Subbed(d.Normal).Len() > 1e-2 {
+			t.Errorf("Incorrect penetration %v for %v & %v, expect normal=%v depth=%v", c, d.A, d.B, d.Normal, d.Depth)
+		}
+	}
+}
+
+func TestTouchingShapedBlocks(t *testing.T) {
+	e := NewEngine(Config{})
+	a := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newBallBlock(ZeroVec, 1))
+	})
+	// the outlines overlap, but the balls are 1.13 apart
+	far := e.NewObject(ManMadeObj, nil, Vec3{0.8, 0.8, 0}, func(o *Object) {
+		o.AddBlock(newBallBlock(ZeroVec, 1))
+	})
+	near := e.NewObject(ManMadeObj, nil, Vec3{0.6, 0.6, 0}, func(o *Object) {
+		o.AddBlock(newBallBlock(ZeroVec, 1))
+	})
+	// a cube touching the ball's side, but not its corner region
+	cube := e.NewObject(ManMadeObj, nil, Vec3{1, 0.8, 0}, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	if a.Touching(far) {
+		t.Errorf("Expect the balls are not touching")
+	}
+	if !a.Touching(near) {
+		t.Errorf("Expect the balls are touching")
+	}
+	if a.Touching(cube) || cube.Touching(a) {
+		t.Errorf("Expect the cube is not touching the ball")
+	}
+	if !near.Touching(cube) || !cube.Touching(near) {
+		t.Errorf("Expect the cube is touching the ball")
+	}
+}
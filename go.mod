@@ -2,4 +2,4 @@ module github.com/LiterMC/molecular
 
 go 1.21.1
 
-require github.com/google/uuid v1.4.1-0.20231123235018-b35aa6a59527 // indirect
+require github.com/google/uuid v1.4.1-0.20231123235018-b35aa6a59527
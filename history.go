@@ -0,0 +1,241 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// HistoryMapping decides which snapshots are kept in the gravity history
+type HistoryMapping uint8
+
+const (
+	// LogHistory keeps the snapshots in log scale, the i-th entry is about 2^i intervals old.
+	// It covers a long delay with a few entries
+	LogHistory HistoryMapping = iota
+	// LinearHistory keeps the latest snapshots, the i-th entry is i+1 intervals old.
+	// It has the same resolution for all delays
+	LinearHistory
+)
+
+func (m HistoryMapping) String() string {
+	switch m {
+	case LogHistory:
+		return "log"
+	case LinearHistory:
+		return "linear"
+	default:
+		return fmt.Sprintf("HistoryMapping(%d)", m)
+	}
+}
+
+const (
+	defaultGravityHistoryLength   = 16
+	defaultGravityHistoryInterval = time.Second
+)
+
+// GravityHistory configures how the gravity fields and the states in the past are kept,
+// so the gravity and the observed states propagate at the speed of light
+type GravityHistory struct {
+	// Length is the count of the snapshots, default is 16
+	Length int
+	// Interval is the time between two snapshots, default is 1s
+	Interval time.Duration
+	// Mapping is the way to map the snapshots to the delays, default is LogHistory
+	Mapping HistoryMapping
+}
+
+func (h *GravityHistory) setDefaults() {
+	if h.Length <= 0 {
+		h.Length = defaultGravityHistoryLength
+	}
+	if h.Interval <= 0 {
+		h.Interval = defaultGravityHistoryInterval
+	}
+}
+
+// gravitySample is a snapshot of the gravity field
+type gravitySample struct {
+	field *GravityField // the position is in the anchor space
+	age   time.Duration // the time since the snapshot was taken
+}
+
+// GravityHistory returns the gravity history config of the object
+func (o *Object) GravityHistory() GravityHistory {
+	o.RLock()
+	defer o.RUnlock()
+	return o.ghistory
+}
+
+// SetGravityHistory changes the gravity history config of the object.
+// The recorded gravity fields and states will be dropped
+func (o *Object) SetGravityHistory(h GravityHistory) {
+	h.setDefaults()
+	o.Lock()
+	defer o.Unlock()
+	o.resetGravityHistoryLocked(h)
+}
+
+func (o *Object) resetGravityHistoryLocked(h GravityHistory) {
+	for _, s := range o.historyGFields {
+		if s.field != nil {
+			gravityFieldPool.Put(s.field)
+		}
+	}
+	o.ghistory = h
+	o.historyGFields = make([]gravitySample, h.Length)
+	o.gfieldUpdateMask = Bitset{}
+	o.gfieldUpdateCd = 0
+	o.historyStates = make([]stateSample, h.Length)
+	o.stateUpdateMask = Bitset{}
+	o.stateUpdateCd = 0
+}
+
+// FillGfields fills the gravity history with the current gravity field,
+// as if the object has been still for a long time
+func (o *Object) FillGfields() {
+	o.Lock()
+	defer o.Unlock()
+	for i := range o.historyGFields {
+		s := &o.historyGFields[i]
+		if s.field != nil {
+			gravityFieldPool.Put(s.field)
+		}
+		s.field = o.gfield.Clone()
+		s.field.pos = o.pos.Added(o.gcenter)
+		s.age = o.ghistory.age(i)
+	}
+}
+
+// maxAge is the age of the snapshots which are older than a time.Duration can hold
+const maxAge = (time.Duration)(math.MaxInt64)
+
+// age returns how old the i-th snapshot is by the mapping, saturated at maxAge
+func (h *GravityHistory) age(i int) time.Duration {
+	if h.Mapping == LinearHistory {
+		if n := (time.Duration)(i + 1); h.Interval <= maxAge/n {
+			return h.Interval * n
+		}
+		return maxAge
+	}
+	if i < 63 && h.Interval <= maxAge>>i {
+		return h.Interval << i
+	}
+	return maxAge
+}
+
+// addAge adds dt to the age, saturated at maxAge
+func addAge(age, dt time.Duration) time.Duration {
+	if age > maxAge-dt {
+		return maxAge
+	}
+	return age + dt
+}
+
+// tickGravityHistoryLocked ages the snapshots, and takes a new snapshot every interval
+func (o *Object) tickGravityHistoryLocked(dt time.Duration) {
+	history := o.historyGFields
+	if len(history) == 0 {
+		return
+	}
+	for i := range history {
+		if history[i].field != nil {
+			history[i].age = addAge(history[i].age, dt)
+		}
+	}
+	if o.gfieldUpdateCd -= dt; o.gfieldUpdateCd >= 0 {
+		return
+	}
+	o.gfieldUpdateCd = o.ghistory.Interval
+
+	snapshot := gravitySample{field: o.gfield.Clone()}
+	snapshot.field.pos = o.pos.Added(o.gcenter)
+	if last := pushHistory(history, &o.gfieldUpdateMask, o.ghistory.Mapping, snapshot); last.field != nil {
+		gravityFieldPool.Put(last.field)
+	}
+}
+
+// pushHistory puts the newest snapshot at the front of the history by the mapping,
+// and returns the snapshot that was dropped
+func pushHistory[T any](history []T, mask *Bitset, mapping HistoryMapping, snapshot T) (dropped T) {
+	if mapping == LinearHistory {
+		dropped = history[len(history)-1]
+		copy(history[1:], history)
+		history[0] = snapshot
+		return
+	}
+	// keep the snapshots in log scale like a binary counter
+	dropped = history[0]
+	history[0] = snapshot
+	for i := 1; i < len(history); i++ {
+		if mask.Flip(i) {
+			break
+		}
+		dropped, history[i] = history[i], dropped
+	}
+	return
+}
+
+// GravityFieldAt returns the gravity acceleration at the position,
+// which is caused by the gravity field when the light now arriving left the object.
+// The snapshots in the history are interpolated by their ages.
+// The current field is used if the light takes less than one interval to arrive.
+// argument pos is the position relative to the zero position of this object
+func (o *Object) GravityFieldAt(pos Vec3) Vec3 {
+	if o.gfield == nil {
+		return ZeroVec
+	}
+	radius := o.gfield.Radius()
+	if pos.SqLen() < radius*radius*4 || len(o.historyGFields) == 0 {
+		return o.gfield.FieldAt(pos)
+	}
+	delay := maxAge
+	if d := pos.Subbed(o.gfield.pos).Len() / C * (float64)(time.Second); d < (float64)(maxAge) {
+		delay = (time.Duration)(d)
+	}
+	if delay < o.ghistory.Interval {
+		return o.gfield.FieldAt(pos)
+	}
+	// convert to the anchor space
+	pos.Add(o.pos)
+	center := o.pos.Added(o.gfield.pos)
+
+	prev := gravitySample{field: o.gfield}
+	prevPos := center
+	for _, s := range o.historyGFields {
+		if s.field == nil || s.age <= prev.age {
+			continue
+		}
+		if s.age >= delay {
+			t := (float64)(delay-prev.age) / (float64)(s.age-prev.age)
+			f := *s.field
+			f.pos = prevPos.Added(s.field.pos.Subbed(prevPos).ScaledN(t))
+			f.mass = prev.field.mass + (s.field.mass-prev.field.mass)*t
+			return f.FieldAt(pos)
+		}
+		prev, prevPos = s, s.field.pos
+	}
+	if delay <= prev.age {
+		f := *prev.field
+		f.pos = prevPos
+		return f.FieldAt(pos)
+	}
+	// the gravity has not arrived yet
+	return ZeroVec
+}
@@ -0,0 +1,98 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func TestGravityHistory(t *testing.T) {
+	e := NewEngine(Config{})
+	if h := e.Config().GravityHistory; h.Length != 16 || h.Interval != time.Second || h.Mapping != LogHistory {
+		t.Fatalf("Unexpected default gravity history %+v", h)
+	}
+	o := e.NewObject(NaturalObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 6e24, nil))
+	})
+	o.SetGravityHistory(GravityHistory{
+		Length:   4,
+		Interval: time.Millisecond,
+		Mapping:  LinearHistory,
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	o.FillGfields()
+
+	// the light takes about 2ms to travel
+	near := o.GravityFieldAt(Vec3{X: 6e5})
+	if near.X >= 0 {
+		t.Errorf("Expect gravity points to the object, got %v", near)
+	}
+	// the light takes about 10ms to travel, which is older than the history
+	if far := o.GravityFieldAt(Vec3{X: 3e6}); far != ZeroVec {
+		t.Errorf("Expect gravity not arrived yet, got %v", far)
+	}
+
+	// the field between two snapshots should be interpolated smoothly
+	var last float64
+	for x := 4e5; x < 1.2e6; x += 1e4 {
+		g := o.GravityFieldAt(Vec3{X: x}).X
+		if last != 0 && g < last {
+			t.Fatalf("Expect gravity decreases smoothly at %v, got %v after %v", x, g, last)
+		}
+		last = g
+	}
+}
+
+func TestGravityHistoryLongLog(t *testing.T) {
+	e := NewEngine(Config{})
+	o := e.NewObject(NaturalObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 6e24, nil))
+	})
+	o.SetGravityHistory(GravityHistory{Length: 48})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	o.FillGfields()
+	e.Tick(time.Millisecond)
+
+	// the light takes about 2^33 seconds to travel, the older snapshots are saturated
+	pos := Vec3{X: 8.8e9 * C}
+	if g := o.GravityFieldAt(pos); g.X >= 0 {
+		t.Errorf("Expect gravity points to the object, got %v", g)
+	}
+}
+
+func TestGravityHistoryNearField(t *testing.T) {
+	e := NewEngine(Config{})
+	o := e.NewObject(NaturalObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 6e24, nil))
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	o.FillGfields()
+	o.SetPos(Vec3{X: 1e7})
+	e.Tick(time.Millisecond)
+
+	// the light takes less than one interval to arrive, the current field is used
+	pos := Vec3{X: -1e8}
+	if g, expect := o.GravityFieldAt(pos), o.GravityField().FieldAt(pos); g != expect {
+		t.Errorf("Expect the current field %v, got %v", expect, g)
+	}
+}
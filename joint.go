@@ -0,0 +1,535 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type JointType uint8
+
+const (
+	// FixedJoint locks both the relative position and the relative rotation
+	FixedJoint JointType = iota
+	// BallJoint locks the anchor points together, and allows rotation on all axes
+	BallJoint
+	// HingeJoint locks the anchor points together, and allows rotation about the axis
+	HingeJoint
+	// SliderJoint locks the relative rotation, and allows translation along the axis
+	SliderJoint
+	// DistanceJoint keeps the anchor points at a fixed distance
+	DistanceJoint
+	// RopeJoint keeps the anchor points not further than the length
+	RopeJoint
+	// SpringJoint pulls the anchor points to the rest length with a spring-damper force
+	SpringJoint
+)
+
+func (t JointType) String() string {
+	switch t {
+	case FixedJoint:
+		return "fixed"
+	case BallJoint:
+		return "ball"
+	case HingeJoint:
+		return "hinge"
+	case SliderJoint:
+		return "slider"
+	case DistanceJoint:
+		return "distance"
+	case RopeJoint:
+		return "rope"
+	case SpringJoint:
+		return "spring"
+	default:
+		return fmt.Sprintf("JointType(%d)", t)
+	}
+}
+
+const (
+	defaultJointIterations = 10
+	// jointBaumgarte is the fraction of the position error that will be corrected in one tick
+	jointBaumgarte = 0.2
+)
+
+// Joint connects two objects at the anchor points in the objects' local space
+type Joint struct {
+	mux sync.Mutex
+
+	typ              JointType
+	a, b             *Object
+	anchorA, anchorB Vec3
+	axisA, axisB     Vec3
+
+	// length is the distance of the distance, rope and spring joints,
+	// negative means use the distance when the joint is solved first time
+	length     float64
+	min, max   float64 // the translation limits of the slider joint
+	limited    bool
+	stiffness  float64
+	damping    float64
+	breakForce float64
+	inited     bool
+	refAxes    [3]Vec3 // the axes of a in b's local space, for locking the relative rotation
+	force      float64
+	broken     bool
+}
+
+// NewJoint creates a joint between the two objects and adds it into the engine.
+// The anchor points are in the objects' local space, same as the blocks' outlines.
+// Nil object means the main anchor
+func (e *Engine) NewJoint(typ JointType, a, b *Object, anchorA, anchorB Vec3, processors ...func(*Joint)) (j *Joint) {
+	if a == nil {
+		a = e.mainAnchor
+	}
+	if b == nil {
+		b = e.mainAnchor
+	}
+	if a == b {
+		panic("molecular.Engine: cannot joint an object with itself")
+	}
+	j = &Joint{
+		typ:     typ,
+		a:       a,
+		b:       b,
+		anchorA: anchorA,
+		anchorB: anchorB,
+		axisA:   UnitY,
+		axisB:   UnitY,
+		length:  -1,
+	}
+	for _, p := range processors {
+		p(j)
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	e.joints = append(e.joints, j)
+	return
+}
+
+// RemoveJoint removes the joint from the engine
+func (e *Engine) RemoveJoint(j *Joint) {
+	e.Lock()
+	defer e.Unlock()
+	e.joints = removeFromSlice(e.joints, j)
+}
+
+// Joints returns the joints in the engine
+func (e *Engine) Joints() []*Joint {
+	e.RLock()
+	defer e.RUnlock()
+	return append(([]*Joint)(nil), e.joints...)
+}
+
+// removeJointsOfLocked removes all the joints that connected to the object.
+// The engine has to be locked
+func (e *Engine) removeJointsOfLocked(o *Object) {
+	for i := 0; i < len(e.joints); {
+		if j := e.joints[i]; j.a == o || j.b == o {
+			e.joints[i] = e.joints[len(e.joints)-1]
+			e.joints[len(e.joints)-1] = nil
+			e.joints = e.joints[:len(e.joints)-1]
+		} else {
+			i++
+		}
+	}
+}
+
+func (j *Joint) Type() JointType {
+	return j.typ
+}
+
+// Objects returns the two objects connected by the joint
+func (j *Joint) Objects() (a, b *Object) {
+	return j.a, j.b
+}
+
+// Anchors returns the anchor points in the objects' local space
+func (j *Joint) Anchors() (a, b Vec3) {
+	return j.anchorA, j.anchorB
+}
+
+// SetAxis sets the hinge or slider axis in the objects' local space, default is UnitY
+func (j *Joint) SetAxis(a, b Vec3) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.axisA, j.axisB = a.Normalized(), b.Normalized()
+}
+
+// SetLength sets the distance of the distance and rope joints, or the rest length of the spring joint.
+// Negative length means use the distance when the joint is solved first time
+func (j *Joint) SetLength(length float64) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.length = length
+}
+
+// SetLimits sets the translation limits along the axis of the slider joint
+func (j *Joint) SetLimits(min, max float64) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.min, j.max = min, max
+	j.limited = true
+}
+
+// SetSpring sets the stiffness in N/m and the damping in N*s/m of the spring joint
+func (j *Joint) SetSpring(stiffness, damping float64) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.stiffness, j.damping = stiffness, damping
+}
+
+// SetBreakForce sets the force in N that will break the joint, zero means unbreakable
+func (j *Joint) SetBreakForce(force float64) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.breakForce = force
+}
+
+// Force returns the magnitude of the linear force applied by the joint during last tick
+func (j *Joint) Force() float64 {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return j.force
+}
+
+// Broken reports whether the joint is broken.
+// A broken joint is removed from the engine
+func (j *Joint) Broken() bool {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return j.broken
+}
+
+// jointBody saves the solving state of an object
+type jointBody struct {
+	obj           *Object
+	invMass       float64
+	invInertia    Vec3 // the inverse principal moments of inertia in local space
+	angle         Vec3
+	gc            Vec3
+	shift         Vec3 // the displacement of the object during this tick
+	vel, angVel   Vec3
+	vel0, angVel0 Vec3
+}
+
+// newJointBody reads the predicted status of the object after this tick
+func newJointBody(o *Object) *jointBody {
+	o.RLock()
+	defer o.RUnlock()
+	o.nextMux.RLock()
+	defer o.nextMux.RUnlock()
+
+	next := &o.nextStatus
+	b := &jointBody{
+		obj:   o,
+		angle: next.angle,
+		gc:    next.gcenter,
+		shift: next.pos.Subbed(o.pos),
+	}
+	if o.anchor != nil && next.mass > 0 {
+		b.invMass = 1 / next.mass
+		inertia := blocksInertia(next.blocks, next.gcenter)
+		b.invInertia = inertia.Mapped(func(n float64) float64 {
+			if n <= 0 {
+				return 0
+			}
+			return 1 / n
+		})
+		b.vel, b.angVel = next.velocity, next.headVel
+	}
+	b.vel0, b.angVel0 = b.vel, b.angVel
+	return b
+}
+
+// mulInvInertia multiplies the vector with the inverse inertia tensor in anchor space
+func (b *jointBody) mulInvInertia(v Vec3) Vec3 {
+	return v.UnrotatedXYZ(b.angle).Scaled(b.invInertia).RotatedXYZ(b.angle)
+}
+
+// offset returns the vector from the gravity center to the local point in anchor space
+func (b *jointBody) offset(p Vec3) Vec3 {
+	return p.Subbed(b.gc).RotatedXYZ(b.angle)
+}
+
+// save writes the velocities back to the object,
+// and moves the object by the velocity change as the integrator does
+func (b *jointBody) save(dt float64) {
+	if b.invMass == 0 {
+		return
+	}
+	o := b.obj
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+	dv, dw := b.vel.Subbed(b.vel0), b.angVel.Subbed(b.angVel0)
+	o.nextStatus.velocity.Add(dv)
+	o.nextStatus.headVel.Add(dw)
+	o.nextStatus.pos.Add(dv.ScaledN(dt / 2))
+	o.nextStatus.angle.Add(dw.ScaledN(dt / 2))
+}
+
+// jointRow is a scalar constraint between two bodies
+type jointRow struct {
+	linA, angA, linB, angB Vec3
+	bias                   float64 // the target velocity along the row
+	invK                   float64
+	lo, hi                 float64 // the limits of the accumulated impulse
+	impulse                float64
+	linear                 bool
+}
+
+func newJointRow(a, b *jointBody, linA, angA, linB, angB Vec3, bias float64) jointRow {
+	k := a.invMass*linA.SqLen() + b.invMass*linB.SqLen() +
+		angA.Dot(a.mulInvInertia(angA)) + angB.Dot(b.mulInvInertia(angB))
+	r := jointRow{
+		linA: linA, angA: angA, linB: linB, angB: angB,
+		bias:   bias,
+		lo:     math.Inf(-1),
+		hi:     math.Inf(1),
+		linear: !linA.IsZero(),
+	}
+	if k > 0 {
+		r.invK = 1 / k
+	}
+	return r
+}
+
+// pointRow constraints the relative velocity of the two points along the direction
+func pointRow(a, b *jointBody, rA, rB, n Vec3, c, dt float64) jointRow {
+	return newJointRow(a, b, n.Negated(), rA.Cross(n).Negated(), n, rB.Cross(n), -jointBaumgarte*c/dt)
+}
+
+// angleRow constraints the relative angular velocity about the axis
+func angleRow(a, b *jointBody, n Vec3, c, dt float64) jointRow {
+	return newJointRow(a, b, ZeroVec, n.Negated(), ZeroVec, n, -jointBaumgarte*c/dt)
+}
+
+func (r *jointRow) apply(a, b *jointBody, lambda float64) {
+	a.vel.Add(r.linA.ScaledN(lambda * a.invMass))
+	a.angVel.Add(a.mulInvInertia(r.angA.ScaledN(lambda)))
+	b.vel.Add(r.linB.ScaledN(lambda * b.invMass))
+	b.angVel.Add(b.mulInvInertia(r.angB.ScaledN(lambda)))
+}
+
+func (r *jointRow) solve(a, b *jointBody, baseVel float64) {
+	jv := r.linA.Dot(a.vel) + r.angA.Dot(a.angVel) + r.linB.Dot(b.vel) + r.angB.Dot(b.angVel) + baseVel
+	lambda := (r.bias - jv) * r.invK
+	acc := math.Max(r.lo, math.Min(r.hi, r.impulse+lambda))
+	lambda, r.impulse = acc-r.impulse, acc
+	r.apply(a, b, lambda)
+}
+
+// perpAxes returns two unit vectors that perpendicular to the axis and each other
+func perpAxes(n Vec3) (t1, t2 Vec3) {
+	if math.Abs(n.X) < 0.6 {
+		t1 = UnitX.Cross(n)
+	} else {
+		t1 = UnitY.Cross(n)
+	}
+	t1.Normalize()
+	t2 = n.Cross(t1)
+	return
+}
+
+// jointSolve saves the prepared rows of a joint
+type jointSolve struct {
+	j      *Joint
+	a, b   *jointBody
+	rows   []jointRow
+	base   []float64 // the relative velocity along each row caused by the different anchors
+	spring float64
+}
+
+// prepare builds the constraint rows of the joint.
+// disp is the displacement from a to b, and base is the velocity of b's anchor relative to a's anchor
+func (j *Joint) prepare(a, b *jointBody, disp, base Vec3, dt float64) (s jointSolve) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	s.j, s.a, s.b = j, a, b
+	rA, rB := a.offset(j.anchorA), b.offset(j.anchorB)
+	// the vector from the anchor point of a to the anchor point of b
+	d := disp.Added(b.gc).Added(rB).Subbed(a.gc).Subbed(rA)
+	dist := d.Len()
+	if !j.inited {
+		j.inited = true
+		if j.length < 0 {
+			j.length = dist
+		}
+		for i, u := range [3]Vec3{UnitX, UnitY, UnitZ} {
+			j.refAxes[i] = u.RotatedXYZ(a.angle).UnrotatedXYZ(b.angle)
+		}
+	}
+
+	lockPoint := func() {
+		for _, n := range [3]Vec3{UnitX, UnitY, UnitZ} {
+			s.rows = append(s.rows, pointRow(a, b, rA, rB, n, d.Dot(n), dt))
+		}
+	}
+	lockRotation := func() {
+		var c Vec3
+		for i, u := range [3]Vec3{UnitX, UnitY, UnitZ} {
+			c.Add(u.RotatedXYZ(a.angle).Cross(j.refAxes[i].RotatedXYZ(b.angle)))
+		}
+		c.ScaleN(0.5)
+		for _, n := range [3]Vec3{UnitX, UnitY, UnitZ} {
+			s.rows = append(s.rows, angleRow(a, b, n, c.Dot(n), dt))
+		}
+	}
+
+	switch j.typ {
+	case FixedJoint:
+		lockPoint()
+		lockRotation()
+	case BallJoint:
+		lockPoint()
+	case HingeJoint:
+		lockPoint()
+		ua, ub := j.axisA.RotatedXYZ(a.angle), j.axisB.RotatedXYZ(b.angle)
+		c := ua.Cross(ub)
+		t1, t2 := perpAxes(ua)
+		s.rows = append(s.rows, angleRow(a, b, t1, c.Dot(t1), dt), angleRow(a, b, t2, c.Dot(t2), dt))
+	case SliderJoint:
+		ua := j.axisA.RotatedXYZ(a.angle)
+		t1, t2 := perpAxes(ua)
+		rAd := rA.Added(d)
+		s.rows = append(s.rows, pointRow(a, b, rAd, rB, t1, d.Dot(t1), dt), pointRow(a, b, rAd, rB, t2, d.Dot(t2), dt))
+		lockRotation()
+		if j.limited {
+			if x := d.Dot(ua); x > j.max {
+				r := pointRow(a, b, rAd, rB, ua, x-j.max, dt)
+				r.hi = 0
+				s.rows = append(s.rows, r)
+			} else if x < j.min {
+				r := pointRow(a, b, rAd, rB, ua, x-j.min, dt)
+				r.lo = 0
+				s.rows = append(s.rows, r)
+			}
+		}
+	case DistanceJoint, RopeJoint:
+		if dist == 0 || j.typ == RopeJoint && dist < j.length {
+			break
+		}
+		n := d.ScaledN(1 / dist)
+		r := pointRow(a, b, rA, rB, n, dist-j.length, dt)
+		if j.typ == RopeJoint {
+			r.hi = 0
+		}
+		s.rows = append(s.rows, r)
+	case SpringJoint:
+		if dist == 0 {
+			break
+		}
+		n := d.ScaledN(1 / dist)
+		r := pointRow(a, b, rA, rB, n, 0, dt)
+		vn := r.linA.Dot(a.vel) + r.angA.Dot(a.angVel) + r.linB.Dot(b.vel) + r.angB.Dot(b.angVel) + base.Dot(n)
+		// the force along n that applies on b
+		f := -j.stiffness*(dist-j.length) - j.damping*vn
+		r.apply(a, b, f*dt)
+		s.spring = math.Abs(f)
+	}
+	s.base = make([]float64, len(s.rows))
+	for i, r := range s.rows {
+		s.base[i] = r.linB.Dot(base)
+	}
+	return
+}
+
+// finish updates the joint force after all iterations,
+// and reports whether the joint should break
+func (s *jointSolve) finish(dt float64) bool {
+	var impulse Vec3
+	for _, r := range s.rows {
+		if r.linear {
+			impulse.Add(r.linB.ScaledN(r.impulse))
+		}
+	}
+	j := s.j
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.force = impulse.Len()/dt + s.spring
+	if j.breakForce > 0 && j.force > j.breakForce {
+		j.broken = true
+	}
+	return j.broken
+}
+
+// solveJoints solves all the joints with sequential impulses,
+// and removes the joints that the force exceeds their break force
+func (e *Engine) solveJoints(dt time.Duration) {
+	e.RLock()
+	if len(e.joints) == 0 {
+		e.RUnlock()
+		return
+	}
+	joints := append(([]*Joint)(nil), e.joints...)
+	e.RUnlock()
+
+	sec := dt.Seconds()
+	bodies := make(map[*Object]*jointBody, len(joints)*2)
+	getBody := func(o *Object) *jointBody {
+		b, ok := bodies[o]
+		if !ok {
+			b = newJointBody(o)
+			bodies[o] = b
+		}
+		return b
+	}
+	solves := make([]jointSolve, 0, len(joints))
+	for _, j := range joints {
+		a, b := getBody(j.a), getBody(j.b)
+		disp := j.a.RelPos(j.b).Added(b.shift).Subbed(a.shift)
+		base := j.b.AbsVelocity().Subbed(j.b.Velocity()).Subbed(j.a.AbsVelocity().Subbed(j.a.Velocity()))
+		solves = append(solves, j.prepare(a, b, disp, base, sec))
+	}
+	iterations := e.cfg.JointIterations
+	for i := 0; i < iterations; i++ {
+		for k := range solves {
+			s := &solves[k]
+			for r := range s.rows {
+				s.rows[r].solve(s.a, s.b, s.base[r])
+			}
+		}
+	}
+	for _, b := range bodies {
+		b.save(sec)
+	}
+
+	var broken []*Joint
+	for k := range solves {
+		if solves[k].finish(sec) {
+			broken = append(broken, solves[k].j)
+		}
+	}
+	if len(broken) == 0 {
+		return
+	}
+	e.Lock()
+	for _, j := range broken {
+		e.joints = removeFromSlice(e.joints, j)
+	}
+	e.Unlock()
+	if e.cfg.OnJointBreak != nil {
+		for _, j := range broken {
+			e.cfg.OnJointBreak(j)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func TestDistanceJoint(t *testing.T) {
+	e := NewEngine(Config{})
+	o1 := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+	})
+	o2 := e.NewObject(ManMadeObj, nil, Vec3{3, 0, 0}, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(Vec3{1, 0, 0})
+	})
+	c := Vec3{0.5, 0.5, 0.5}
+	j := e.NewJoint(DistanceJoint, o1, o2, c, c)
+	for i := 0; i < 100; i++ {
+		e.Tick(time.Millisecond * 10)
+	}
+	if d := o1.RelPos(o2).Len(); math.Abs(d-3) > 0.05 {
+		t.Errorf("Expect distance stays 3, got %v", d)
+	}
+	if v := o1.Velocity().Added(o2.Velocity()); math.Abs(v.X-1) > 1e-6 {
+		t.Errorf("Expect momentum conserved, got total velocity %v", v)
+	}
+	if j.Force() <= 0 {
+		t.Errorf("Expect joint applies force")
+	}
+}
+
+func TestRopeJoint(t *testing.T) {
+	e := NewEngine(Config{})
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(Vec3{0, 1, 0})
+	})
+	c := Vec3{0.5, 0.5, 0.5}
+	e.NewJoint(RopeJoint, nil, o, c, c, func(j *Joint) {
+		j.SetLength(1)
+	})
+	e.Tick(time.Millisecond * 100)
+	if v := o.Velocity(); v.Y != 1 {
+		t.Errorf("Expect slack rope applies no force, got velocity %v", v)
+	}
+	for i := 0; i < 100; i++ {
+		e.Tick(time.Millisecond * 10)
+	}
+	if p := o.Pos(); p.Len() > 1.05 {
+		t.Errorf("Expect rope holds the object within 1, got %v", p)
+	}
+}
+
+func TestJointBreak(t *testing.T) {
+	var broken []*Joint
+	e := NewEngine(Config{
+		OnJointBreak: func(j *Joint) {
+			broken = append(broken, j)
+		},
+	})
+	o1 := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+	})
+	o2 := e.NewObject(ManMadeObj, nil, UnitX, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+	})
+	j := e.NewJoint(FixedJoint, o1, o2, UnitX, ZeroVec, func(j *Joint) {
+		j.SetBreakForce(100)
+	})
+	e.Tick(time.Millisecond * 10)
+	if len(e.Joints()) != 1 || j.Broken() {
+		t.Fatalf("Expect joint not broken at rest")
+	}
+	o2.SetVelocity(Vec3{10, 0, 0})
+	e.Tick(time.Millisecond * 10)
+	if len(broken) != 1 || broken[0] != j || !j.Broken() || len(e.Joints()) != 0 {
+		t.Errorf("Expect joint broken by large force")
+	}
+}
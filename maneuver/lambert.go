@@ -0,0 +1,117 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package maneuver
+
+import (
+	"math"
+
+	"github.com/LiterMC/molecular"
+)
+
+const lambertIterations = 200
+
+// stumpffC returns the Stumpff function C(z)
+func stumpffC(z float64) float64 {
+	switch {
+	case z > 0:
+		return (1 - math.Cos(math.Sqrt(z))) / z
+	case z < 0:
+		return (math.Cosh(math.Sqrt(-z)) - 1) / -z
+	default:
+		return 0.5
+	}
+}
+
+// stumpffS returns the Stumpff function S(z)
+func stumpffS(z float64) float64 {
+	switch {
+	case z > 0:
+		s := math.Sqrt(z)
+		return (s - math.Sin(s)) / (s * s * s)
+	case z < 0:
+		s := math.Sqrt(-z)
+		return (math.Sinh(s) - s) / (s * s * s)
+	default:
+		return 1.0 / 6
+	}
+}
+
+// Lambert solves Lambert's problem with universal variables.
+// It returns the velocities at r1 and r2 of the single revolution orbit that
+// travels from r1 to r2 in tof seconds. The direction of motion is decided
+// by the Z axis of the anchor space, same as the reference plane of OrbitalElements.
+// It reports false if there is no solution
+func Lambert(mu float64, r1, r2 molecular.Vec3, tof float64, prograde bool) (v1, v2 molecular.Vec3, ok bool) {
+	if tof <= 0 {
+		return
+	}
+	l1, l2 := r1.Len(), r2.Len()
+	cosT := math.Max(-1, math.Min(1, r1.Dot(r2)/(l1*l2)))
+	theta := math.Acos(cosT)
+	if z := r1.Cross(r2).Z; prograde == (z < 0) {
+		theta = 2*math.Pi - theta
+	}
+	a := math.Sin(theta) * math.Sqrt(l1*l2/(1-cosT))
+	if a == 0 || math.IsNaN(a) {
+		return
+	}
+	y := func(z float64) float64 {
+		return l1 + l2 + a*(z*stumpffS(z)-1)/math.Sqrt(stumpffC(z))
+	}
+	sqrtMu := math.Sqrt(mu)
+	// the time of flight is monotonic increasing with z
+	timeOf := func(z float64) float64 {
+		yz := y(z)
+		c := stumpffC(z)
+		return (math.Pow(yz/c, 1.5)*stumpffS(z) + a*math.Sqrt(yz)) / sqrtMu
+	}
+
+	lo, hi := -4*math.Pi*math.Pi, 4*math.Pi*math.Pi*(1-1e-12)
+	for y(lo) < 0 || timeOf(lo) > tof {
+		lo /= 2
+		if lo > -1e-12 {
+			// y is negative at all lower z when a > 0, so search the positive range
+			lo = 0
+			for i := 0; i < lambertIterations && y(lo) < 0; i++ {
+				lo = (lo + hi) / 2
+			}
+			break
+		}
+	}
+	if y(lo) < 0 || timeOf(lo) > tof || timeOf(hi) < tof {
+		return
+	}
+	var z float64
+	for i := 0; i < lambertIterations; i++ {
+		z = (lo + hi) / 2
+		if timeOf(z) < tof {
+			lo = z
+		} else {
+			hi = z
+		}
+		if hi-lo < 1e-14 {
+			break
+		}
+	}
+	yz := y(z)
+	f := 1 - yz/l1
+	g := a * math.Sqrt(yz/mu)
+	gdot := 1 - yz/l2
+	v1 = r2.Subbed(r1.ScaledN(f)).ScaledN(1 / g)
+	v2 = r2.ScaledN(gdot).Subbed(r1).ScaledN(1 / g)
+	return v1, v2, true
+}
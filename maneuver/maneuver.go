@@ -0,0 +1,151 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package maneuver plans orbital maneuvers around an anchor's gravity,
+// and converts them into burn schedules that can be executed by Object.TickForce
+package maneuver
+
+import (
+	"math"
+	"time"
+
+	"github.com/LiterMC/molecular"
+)
+
+// Burn is an impulsive velocity change
+type Burn struct {
+	Time   time.Duration  // the time since the plan starts
+	DeltaV molecular.Vec3 // the velocity change in the anchor space
+}
+
+// Plan is a sequence of impulsive burns
+type Plan struct {
+	Burns []Burn
+}
+
+// TotalDeltaV returns the sum of the burns' delta-v magnitudes
+func (p Plan) TotalDeltaV() (dv float64) {
+	for _, b := range p.Burns {
+		dv += b.DeltaV.Len()
+	}
+	return
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return (time.Duration)(s * (float64)(time.Second))
+}
+
+// HohmannDeltaV returns the delta-v magnitudes and the time of flight of the Hohmann transfer
+// between two coplanar circular orbits
+func HohmannDeltaV(mu, r1, r2 float64) (dv1, dv2, tof float64) {
+	a := (r1 + r2) / 2
+	dv1 = math.Abs(math.Sqrt(mu*(2/r1-1/a)) - math.Sqrt(mu/r1))
+	dv2 = math.Abs(math.Sqrt(mu/r2) - math.Sqrt(mu*(2/r2-1/a)))
+	tof = math.Pi * math.Sqrt(a*a*a/mu)
+	return
+}
+
+// BiEllipticDeltaV returns the delta-v magnitudes and the time of flight of the bi-elliptic transfer
+// between two coplanar circular orbits, via the intermediate apoapsis rb
+func BiEllipticDeltaV(mu, r1, r2, rb float64) (dv1, dv2, dv3, tof float64) {
+	a1, a2 := (r1+rb)/2, (r2+rb)/2
+	dv1 = math.Abs(math.Sqrt(mu*(2/r1-1/a1)) - math.Sqrt(mu/r1))
+	dv2 = math.Abs(math.Sqrt(mu*(2/rb-1/a2)) - math.Sqrt(mu*(2/rb-1/a1)))
+	dv3 = math.Abs(math.Sqrt(mu*(2/r2-1/a2)) - math.Sqrt(mu/r2))
+	tof = math.Pi * (math.Sqrt(a1*a1*a1/mu) + math.Sqrt(a2*a2*a2/mu))
+	return
+}
+
+// PlaneChangeDeltaV returns the delta-v magnitude to rotate the velocity by the angle
+func PlaneChangeDeltaV(speed, angle float64) float64 {
+	return 2 * speed * math.Abs(math.Sin(angle/2))
+}
+
+// tangent returns the prograde horizontal direction at the position
+func tangent(r, v molecular.Vec3) molecular.Vec3 {
+	h := r.Cross(v)
+	return h.Cross(r).Normalized()
+}
+
+// apsisBurn changes the velocity at the position to be horizontal,
+// so that the position is an apsis and the other apsis is at the radius ra.
+// It returns the delta-v, and the state at the other apsis
+func apsisBurn(mu float64, r, v molecular.Vec3, ra float64) (dv molecular.Vec3, r2, v2 molecular.Vec3, tof float64) {
+	rl := r.Len()
+	a := (rl + ra) / 2
+	nv := tangent(r, v).ScaledN(math.Sqrt(mu * (2/rl - 1/a)))
+	dv = nv.Subbed(v)
+	tof = math.Pi * math.Sqrt(a*a*a/mu)
+	r2, v2 = molecular.ElementsFromState(mu, r, nv).Propagate(mu, tof).StateVectors(mu)
+	return
+}
+
+// circularize returns the delta-v to make the orbit circular at the position
+func circularize(mu float64, r, v molecular.Vec3) molecular.Vec3 {
+	return tangent(r, v).ScaledN(math.Sqrt(mu / r.Len())).Subbed(v)
+}
+
+// Hohmann plans the Hohmann transfer from the current circular orbit to the circular orbit with the radius.
+// r and v are the current state relative to the central body
+func Hohmann(mu float64, r, v molecular.Vec3, radius float64) Plan {
+	dv1, r2, v2, tof := apsisBurn(mu, r, v, radius)
+	return Plan{
+		Burns: []Burn{
+			{Time: 0, DeltaV: dv1},
+			{Time: secondsToDuration(tof), DeltaV: circularize(mu, r2, v2)},
+		},
+	}
+}
+
+// BiElliptic plans the bi-elliptic transfer from the current circular orbit to the circular orbit with the radius,
+// via the intermediate apoapsis rb
+func BiElliptic(mu float64, r, v molecular.Vec3, radius, rb float64) Plan {
+	dv1, r2, v2, tof1 := apsisBurn(mu, r, v, rb)
+	dv2, r3, v3, tof2 := apsisBurn(mu, r2, v2, radius)
+	return Plan{
+		Burns: []Burn{
+			{Time: 0, DeltaV: dv1},
+			{Time: secondsToDuration(tof1), DeltaV: dv2},
+			{Time: secondsToDuration(tof1 + tof2), DeltaV: circularize(mu, r3, v3)},
+		},
+	}
+}
+
+// PlaneChange returns the burn that rotates the velocity about the position vector by the angle.
+// It's the cheapest at the ascending or the descending node
+func PlaneChange(r, v molecular.Vec3, angle float64) Burn {
+	k := r.Normalized()
+	s, c := math.Sincos(angle)
+	// Rodrigues' rotation formula
+	nv := v.ScaledN(c).Added(k.Cross(v).ScaledN(s)).Added(k.ScaledN(k.Dot(v) * (1 - c)))
+	return Burn{DeltaV: nv.Subbed(v)}
+}
+
+// Rendezvous plans the transfer from the current state to the target state after the time of flight,
+// by solving Lambert's problem. The target state is the position and the velocity at the arrival.
+// It reports false if Lambert's problem has no solution
+func Rendezvous(mu float64, r, v molecular.Vec3, targetR, targetV molecular.Vec3, tof time.Duration, prograde bool) (Plan, bool) {
+	v1, v2, ok := Lambert(mu, r, targetR, tof.Seconds(), prograde)
+	if !ok {
+		return Plan{}, false
+	}
+	return Plan{
+		Burns: []Burn{
+			{Time: 0, DeltaV: v1.Subbed(v)},
+			{Time: tof, DeltaV: targetV.Subbed(v2)},
+		},
+	}, true
+}
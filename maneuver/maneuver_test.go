@@ -0,0 +1,124 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package maneuver_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+	. "github.com/LiterMC/molecular/maneuver"
+)
+
+const earthMu = 3.986004418e14
+
+// execute applies the impulsive burns of the plan along the Kepler orbit
+func execute(mu float64, r, v Vec3, p Plan) (Vec3, Vec3) {
+	var t time.Duration
+	for _, b := range p.Burns {
+		r, v = ElementsFromState(mu, r, v).Propagate(mu, (b.Time - t).Seconds()).StateVectors(mu)
+		t = b.Time
+		v.Add(b.DeltaV)
+	}
+	return r, v
+}
+
+func circular(mu, radius float64) (Vec3, Vec3) {
+	return Vec3{X: radius, Y: 0, Z: 0}, Vec3{X: 0, Y: math.Sqrt(mu / radius), Z: 0}
+}
+
+func TestHohmann(t *testing.T) {
+	r, v := circular(earthMu, 7e6)
+	plan := Hohmann(earthMu, r, v, 4.2e7)
+	dv1, dv2, tof := HohmannDeltaV(earthMu, 7e6, 4.2e7)
+	if len(plan.Burns) != 2 || math.Abs(plan.TotalDeltaV()-dv1-dv2) > 1e-3 {
+		t.Fatalf("Expect total delta-v %v, got %v", dv1+dv2, plan.TotalDeltaV())
+	}
+	if d := plan.Burns[1].Time.Seconds() - tof; math.Abs(d) > 1e-3 {
+		t.Errorf("Expect time of flight %v, got %v", tof, plan.Burns[1].Time)
+	}
+	r, v = execute(earthMu, r, v, plan)
+	if el := ElementsFromState(earthMu, r, v); math.Abs(el.A-4.2e7) > 1 || el.E > 1e-6 {
+		t.Errorf("Expect circular orbit at 4.2e7, got %+v", el)
+	}
+}
+
+func TestBiElliptic(t *testing.T) {
+	r, v := circular(earthMu, 7e6)
+	plan := BiElliptic(earthMu, r, v, 1.05e8, 2e8)
+	dv1, dv2, dv3, _ := BiEllipticDeltaV(earthMu, 7e6, 1.05e8, 2e8)
+	if len(plan.Burns) != 3 || math.Abs(plan.TotalDeltaV()-dv1-dv2-dv3) > 1e-3 {
+		t.Fatalf("Expect total delta-v %v, got %v", dv1+dv2+dv3, plan.TotalDeltaV())
+	}
+	r, v = execute(earthMu, r, v, plan)
+	if el := ElementsFromState(earthMu, r, v); math.Abs(el.A-1.05e8) > 10 || el.E > 1e-6 {
+		t.Errorf("Expect circular orbit at 1.05e8, got %+v", el)
+	}
+}
+
+func TestPlaneChange(t *testing.T) {
+	r, v := circular(earthMu, 7e6)
+	b := PlaneChange(r, v, 0.5)
+	if d := b.DeltaV.Len() - PlaneChangeDeltaV(v.Len(), 0.5); math.Abs(d) > 1e-6 {
+		t.Errorf("Expect delta-v %v, got %v", PlaneChangeDeltaV(v.Len(), 0.5), b.DeltaV.Len())
+	}
+	if el := ElementsFromState(earthMu, r, v.Added(b.DeltaV)); math.Abs(el.I-0.5) > 1e-9 {
+		t.Errorf("Expect inclination 0.5, got %v", el.I)
+	}
+}
+
+func TestLambert(t *testing.T) {
+	r1 := Vec3{X: 7e6, Y: 1e6, Z: 0}
+	r2 := Vec3{X: -2e6, Y: 9e6, Z: 1e6}
+	for _, prograde := range []bool{true, false} {
+		for _, tof := range []float64{1000, 3000, 20000} {
+			v1, v2, ok := Lambert(earthMu, r1, r2, tof, prograde)
+			if !ok {
+				t.Errorf("Lambert's problem has no solution for tof %v", tof)
+				continue
+			}
+			p, v := ElementsFromState(earthMu, r1, v1).Propagate(earthMu, tof).StateVectors(earthMu)
+			if p.Subbed(r2).Len() > 1 || v.Subbed(v2).Len() > 1e-3 {
+				t.Errorf("Expect arrive %v %v, got %v %v", r2, v2, p, v)
+			}
+			if h := r1.Cross(v1).Z; prograde != (h > 0) {
+				t.Errorf("Expect prograde %v, got angular momentum z %v", prograde, h)
+			}
+		}
+	}
+}
+
+func TestScheduleForce(t *testing.T) {
+	plan := Plan{Burns: []Burn{
+		{Time: time.Second, DeltaV: Vec3{X: 10, Y: 0, Z: 0}},
+		{Time: time.Second * 5, DeltaV: Vec3{X: 0, Y: -4, Z: 0}},
+	}}
+	s := plan.Schedule(4)
+	const mass = 2.0
+	var dv Vec3
+	step := time.Millisecond * 300
+	for ts := time.Duration(0); ts < s.End(); ts += step {
+		dv.Add(s.Force(ts, step, mass).ScaledN(step.Seconds() / mass))
+	}
+	if dv.Subbed(Vec3{X: 10, Y: -4, Z: 0}).Len() > 1e-9 {
+		t.Errorf("Expect scheduled delta-v (10, -4, 0), got %v", dv)
+	}
+	if s[0].Start != 0 || s[0].Duration != time.Millisecond*2500 {
+		t.Errorf("Unexpected finite burn %+v", s[0])
+	}
+}
@@ -0,0 +1,91 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package maneuver
+
+import (
+	"time"
+
+	"github.com/LiterMC/molecular"
+)
+
+// FiniteBurn is a burn that spreads the velocity change over a duration
+type FiniteBurn struct {
+	Start    time.Duration // the time since the schedule starts
+	Duration time.Duration
+	DeltaV   molecular.Vec3
+}
+
+// Schedule is a sequence of finite burns
+type Schedule []FiniteBurn
+
+// Schedule converts the impulsive burns into finite burns with the maximum acceleration in m/s^2.
+// Each burn is centered on its impulse time, but never starts before the schedule
+func (p Plan) Schedule(maxAccel float64) (s Schedule) {
+	if maxAccel <= 0 {
+		panic("maneuver.Plan: max acceleration must be positive")
+	}
+	s = make(Schedule, 0, len(p.Burns))
+	for _, b := range p.Burns {
+		d := secondsToDuration(b.DeltaV.Len() / maxAccel)
+		start := b.Time - d/2
+		if start < 0 {
+			start = 0
+		}
+		s = append(s, FiniteBurn{
+			Start:    start,
+			Duration: d,
+			DeltaV:   b.DeltaV,
+		})
+	}
+	return
+}
+
+// End returns the time when the last burn ends
+func (s Schedule) End() (end time.Duration) {
+	for _, b := range s {
+		if e := b.Start + b.Duration; e > end {
+			end = e
+		}
+	}
+	return
+}
+
+// Force returns the average force that should be applied on the object with the mass
+// during [t, t + dt). The result can be added into Object.TickForce inside a tick
+func (s Schedule) Force(t, dt time.Duration, mass float64) (force molecular.Vec3) {
+	if dt <= 0 {
+		return
+	}
+	end := t + dt
+	for _, b := range s {
+		if b.Duration <= 0 {
+			// instant burn
+			if t <= b.Start && b.Start < end {
+				force.Add(b.DeltaV.ScaledN(mass / dt.Seconds()))
+			}
+			continue
+		}
+		bend := b.Start + b.Duration
+		if bend <= t || b.Start >= end {
+			continue
+		}
+		lo, hi := max(t, b.Start), min(end, bend)
+		frac := (float64)(hi-lo) / (float64)(b.Duration)
+		force.Add(b.DeltaV.ScaledN(mass * frac / dt.Seconds()))
+	}
+	return
+}
@@ -0,0 +1,356 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+)
+
+// DockingPort is a Block that can dock with another DockingPort
+type DockingPort interface {
+	Block
+	// CanDock reports whether the port is able to dock with the other one
+	CanDock(other DockingPort) bool
+}
+
+// frameTransform converts the object local positions of one object to another's
+type frameTransform struct {
+	disp              Vec3 // the displacement from the target's zero position to the source's
+	srcGc, srcAngle   Vec3
+	destGc, destAngle Vec3
+}
+
+// frameTransformLocked returns the transform from a's local space to o's local space,
+// disp is the displacement from o to a. Both objects have to be read locked
+func (o *Object) frameTransformLocked(a *Object, disp Vec3) frameTransform {
+	return frameTransform{
+		disp:      disp,
+		srcGc:     a.gcenter,
+		srcAngle:  a.angle,
+		destGc:    o.gcenter,
+		destAngle: o.angle,
+	}
+}
+
+// Pos converts the source local position to the target local position
+func (t *frameTransform) Pos(p Vec3) Vec3 {
+	p.Sub(t.srcGc).RotateXYZ(t.srcAngle).Add(t.srcGc).Add(t.disp)
+	p.Sub(t.destGc).UnrotateXYZ(t.destAngle).Add(t.destGc)
+	return p
+}
+
+// Cube converts the source local cube to the target local cube.
+// Since Cube is axis-aligned, the relative rotation is snapped to the nearest multiple of 90°,
+// so the size of the cube is kept and only its axes are permuted
+func (t *frameTransform) Cube(c *Cube) *Cube {
+	size := t.Size(c.S)
+	center := t.Pos(c.Center())
+	return NewCube(center.Subbed(size.ScaledN(0.5)), size)
+}
+
+// axisPermutations are all the ways to map the three source axes to the target axes
+var axisPermutations = [...][3]int{
+	{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0},
+}
+
+// Size converts the source local size to the target local size,
+// with the relative rotation snapped to the nearest multiple of 90°
+func (t *frameTransform) Size(s Vec3) Vec3 {
+	// align[i][j] is how much the source axis i is aligned to the target axis j
+	var align [3][3]float64
+	for i, u := range [3]Vec3{UnitX, UnitY, UnitZ} {
+		x, y, z := u.RotatedXYZ(t.srcAngle).UnrotatedXYZ(t.destAngle).Abs().XYZ()
+		align[i] = [3]float64{x, y, z}
+	}
+	best, bestScore := axisPermutations[0], -1.0
+	for _, p := range axisPermutations {
+		if score := align[0][p[0]] + align[1][p[1]] + align[2][p[2]]; score > bestScore {
+			best, bestScore = p, score
+		}
+	}
+	var src, dst [3]float64
+	src[0], src[1], src[2] = s.XYZ()
+	for i, j := range best {
+		dst[j] = src[i]
+	}
+	return Vec3{dst[0], dst[1], dst[2]}
+}
+
+// alignEpsilon is the tolerance of the axis alignment in Aligned
+const alignEpsilon = 1e-6
+
+// Aligned reports whether the relative rotation is a multiple of 90°,
+// which means each source axis is mapped to a target axis
+func (t *frameTransform) Aligned() bool {
+	for _, u := range [3]Vec3{UnitX, UnitY, UnitZ} {
+		x, y, z := u.RotatedXYZ(t.srcAngle).UnrotatedXYZ(t.destAngle).Abs().XYZ()
+		if math.Max(x, math.Max(y, z)) < 1-alignEpsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// blocksInertia returns the approximate principal moments of inertia of the blocks about the center.
+// The products of inertia are ignored
+func blocksInertia(blocks []Block, center Vec3) (inertia Vec3) {
+	for _, b := range blocks {
+		m := b.Mass()
+		l := b.Outline()
+		r := l.Center().Subbed(center)
+		s := l.S
+		inertia.X += m * (r.Y*r.Y + r.Z*r.Z + (s.Y*s.Y+s.Z*s.Z)/12)
+		inertia.Y += m * (r.X*r.X + r.Z*r.Z + (s.X*s.X+s.Z*s.Z)/12)
+		inertia.Z += m * (r.X*r.X + r.Y*r.Y + (s.X*s.X+s.Y*s.Y)/12)
+	}
+	return
+}
+
+// Inertia returns the approximate principal moments of inertia about the object's gravity center.
+// The children are not included
+func (o *Object) Inertia() Vec3 {
+	o.RLock()
+	defer o.RUnlock()
+	return blocksInertia(o.blocks, o.gcenter)
+}
+
+// Touching reports whether any block of the object is overlapping or touching
+// with any block of the other object. The orientations of the objects are applied.
+// The blocks are tested as OBB, unless one of them is a ShapedBlock,
+// then the shapes will be tested with GJK
+func (o *Object) Touching(a *Object) bool {
+	disp := o.RelPos(a)
+
+	o.RLock()
+	defer o.RUnlock()
+	a.RLock()
+	defer a.RUnlock()
+
+	touching := false
+	for _, ab := range a.blocks {
+		if _, ok := ab.(ShapedBlock); ok {
+			s := TransformShape(BlockShape(ab), disp, a.gcenter, a.angle)
+			o.queryRelBoxLocked(s.Bounds(), func(b Block) bool {
+				touching = Intersect(TransformShape(BlockShape(b), ZeroVec, o.gcenter, o.angle), s)
+				return !touching
+			})
+		} else {
+			x := NewOBBFromCube(ab.Outline(), disp, a.gcenter, a.angle)
+			o.queryRelBoxLocked(x.Bounds(), func(b Block) bool {
+				if _, ok := b.(ShapedBlock); ok {
+					touching = Intersect(TransformShape(BlockShape(b), ZeroVec, o.gcenter, o.angle), x)
+				} else {
+					touching = NewOBBFromCube(b.Outline(), ZeroVec, o.gcenter, o.angle).Overlap(x)
+				}
+				return !touching
+			})
+		}
+		if touching {
+			return true
+		}
+	}
+	return false
+}
+
+// RelSpeed returns the length of the velocity of the other object relative to this object
+func (o *Object) RelSpeed(a *Object) float64 {
+	return a.AbsVelocity().Subbed(o.AbsVelocity()).Len()
+}
+
+// TryMerge merges the other object into this object if they are touching,
+// and their relative speed is below Config.MergeSpeed.
+// It reports whether the objects are merged
+func (o *Object) TryMerge(a *Object) bool {
+	if o.RelSpeed(a) >= o.e.cfg.MergeSpeed || !o.Touching(a) {
+		return false
+	}
+	o.Merge(a)
+	return true
+}
+
+// TryDock merges the other object into this object if a pair of DockingPort
+// from the two objects are aligned face to face and they can dock with each other.
+// It reports whether the objects are merged
+func (o *Object) TryDock(a *Object) bool {
+	disp := o.RelPos(a)
+
+	o.RLock()
+	a.RLock()
+	t := o.frameTransformLocked(a, disp)
+	docked := false
+	for _, ab := range a.blocks {
+		ap, ok := ab.(DockingPort)
+		if !ok {
+			continue
+		}
+		l := t.Cube(ap.Outline())
+		for _, b := range o.blocks {
+			if p, ok := b.(DockingPort); ok && p.Outline().SharesFace(l) && p.CanDock(ap) && ap.CanDock(p) {
+				docked = true
+				break
+			}
+		}
+		if docked {
+			break
+		}
+	}
+	a.RUnlock()
+	o.RUnlock()
+
+	if docked {
+		o.Merge(a)
+	}
+	return docked
+}
+
+// Merge absorbs the other object into this object.
+// The blocks are removed from the absorbed object first, then their outlines are moved
+// into this object's local space in place. Since the outlines are axis-aligned,
+// the relative rotation between the objects is snapped to the nearest multiple of 90°.
+// the linear and angular momentum are conserved,
+// the children of the absorbed object will be attached to this object,
+// and the absorbed object will be removed from the engine.
+// Merge should not be called inside a tick
+func (o *Object) Merge(a *Object) {
+	if a == o {
+		panic("molecular.Object: cannot merge an object with itself")
+	}
+	disp := o.RelPos(a)
+	va := a.AbsVelocity()
+	if anchor := o.Anchor(); anchor != nil {
+		va.Sub(anchor.AbsVelocity())
+	}
+
+	a.Lock()
+	a.nextMux.Lock()
+	children := append(([]*Object)(nil), a.nextStatus.children...)
+	src := mergeSource{
+		blocks:   append(([]Block)(nil), a.blocks...),
+		disp:     disp,
+		gcenter:  a.gcenter,
+		angle:    a.angle,
+		mass:     a.mass,
+		velocity: va,
+		headVel:  a.headVel,
+		inertia:  blocksInertia(a.blocks, a.gcenter),
+		damages:  make(map[Block]float64, len(a.damages)),
+	}
+	for b, d := range a.damages {
+		src.damages[b] = d
+	}
+	// the absorbed object gives up its blocks before their outlines are changed
+	a.objStatus.blocks = nil
+	a.nextStatus.blocks = nil
+	a.index.Reset(nil)
+	clear(a.damages)
+	a.nextMux.Unlock()
+	a.Unlock()
+
+	o.mergeFrom(&src)
+
+	// attach the children after updated the velocity, so their relative velocities are correct
+	for _, c := range children {
+		c.AttachTo(o)
+	}
+	o.e.RemoveObject(a)
+}
+
+// mergeSource saves the status of the object that is going to be absorbed
+type mergeSource struct {
+	blocks   []Block
+	disp     Vec3 // the displacement from the target object
+	gcenter  Vec3
+	angle    Vec3
+	mass     float64
+	velocity Vec3 // the velocity relative to the target's anchor
+	headVel  Vec3
+	inertia  Vec3
+	damages  map[Block]float64
+}
+
+func (o *Object) mergeFrom(src *mergeSource) {
+	o.Lock()
+	defer o.Unlock()
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+
+	t := frameTransform{
+		disp:      src.disp,
+		srcGc:     src.gcenter,
+		srcAngle:  src.angle,
+		destGc:    o.gcenter,
+		destAngle: o.angle,
+	}
+	for _, b := range src.blocks {
+		l := b.Outline()
+		// the outline is owned by the block as Block.Outline requires, so it's moved in place
+		*l = *t.Cube(l)
+		b.SetObject(o)
+		o.index.Add(b)
+	}
+	blocks := append(o.blocks, src.blocks...)
+
+	var (
+		mo, ma = o.mass, src.mass
+		vo, va = o.velocity, src.velocity
+		ogc    = o.gcenter
+		// the gravity centers relative to this object's zero position
+		co = ogc
+		ca = src.gcenter.Added(src.disp)
+	)
+	gc, mass := blocksCenterAndMass(blocks)
+	vel, cm := vo, co
+	if total := mo + ma; total > 0 {
+		vel = vo.ScaledN(mo / total).Added(va.ScaledN(ma / total))
+		cm = co.ScaledN(mo / total).Added(ca.ScaledN(ma / total))
+	}
+	// the angular momentum about the new gravity center
+	momentum := o.headVel.Scaled(blocksInertia(o.blocks, ogc)).
+		Added(src.headVel.Scaled(src.inertia)).
+		Added(co.Subbed(cm).Cross(vo.Subbed(vel)).ScaledN(mo)).
+		Added(ca.Subbed(cm).Cross(va.Subbed(vel)).ScaledN(ma))
+	inertia := blocksInertia(blocks, gc)
+	var headVel Vec3
+	if inertia.X > 0 {
+		headVel.X = momentum.X / inertia.X
+	}
+	if inertia.Y > 0 {
+		headVel.Y = momentum.Y / inertia.Y
+	}
+	if inertia.Z > 0 {
+		headVel.Z = momentum.Z / inertia.Z
+	}
+
+	offset := pivotOffset(ogc, o.angle).Subbed(pivotOffset(gc, o.angle))
+	o.blocks = blocks
+	o.nextStatus.blocks = append(o.nextStatus.blocks, src.blocks...)
+	o.gcenter, o.nextStatus.gcenter = gc, gc
+	o.mass, o.nextStatus.mass = mass, mass
+	o.pos.Add(offset)
+	o.nextStatus.pos.Add(offset)
+	o.velocity, o.nextStatus.velocity = vel, vel
+	o.headVel, o.nextStatus.headVel = headVel, headVel
+	if len(src.damages) > 0 {
+		if o.damages == nil {
+			o.damages = src.damages
+		} else {
+			for b, d := range src.damages {
+				o.damages[b] += d
+			}
+		}
+	}
+}
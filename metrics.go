@@ -0,0 +1,243 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"runtime/trace"
+	"time"
+)
+
+// TickPhase is a phase of Engine.Tick
+type TickPhase uint8
+
+const (
+	// PhaseObjects ticks the objects, the continuous collision detection and the joints
+	PhaseObjects TickPhase = iota
+	// PhaseEvents ticks the event waves
+	PhaseEvents
+	// PhaseSync saves the next status of the objects and removes the dead event waves
+	PhaseSync
+	// PhaseBreak processes the broken blocks, the splitting and the accretion
+	PhaseBreak
+
+	tickPhaseCount
+)
+
+func (p TickPhase) String() string {
+	switch p {
+	case PhaseObjects:
+		return "objects"
+	case PhaseEvents:
+		return "events"
+	case PhaseSync:
+		return "sync"
+	case PhaseBreak:
+		return "break"
+	default:
+		return fmt.Sprintf("TickPhase(%d)", p)
+	}
+}
+
+// PoolStats is the statistics of an internal object pool
+type PoolStats struct {
+	Name   string
+	Gets   uint64
+	Misses uint64 // the count of the Gets that allocated a new object
+}
+
+// HitRate returns the ratio of the Gets that reused an object
+func (s PoolStats) HitRate() float64 {
+	if s.Gets == 0 {
+		return 0
+	}
+	return (float64)(s.Gets-s.Misses) / (float64)(s.Gets)
+}
+
+// PoolStatistics returns the statistics of all the internal object pools since the program started
+func PoolStatistics() []PoolStats {
+	poolsMux.Lock()
+	defer poolsMux.Unlock()
+	stats := make([]PoolStats, len(pools))
+	for i, p := range pools {
+		stats[i] = p.Stats()
+	}
+	return stats
+}
+
+// TickStats is the metrics of one Engine.Tick
+type TickStats struct {
+	DT       time.Duration
+	Duration time.Duration
+	Phases   [tickPhaseCount]time.Duration
+	// Objects is the count of the objects when the tick started
+	Objects int
+	// EventWaves is the count of the active event waves
+	EventWaves int
+	// HeavyEvents is the count of the event waves that ticked in separate goroutines
+	HeavyEvents int
+	// WaveObjects is the count of the objects reached by each event wave
+	WaveObjects []int
+	Pools       []PoolStats
+}
+
+// Phase returns the duration of the phase
+func (s *TickStats) Phase(p TickPhase) time.Duration {
+	return s.Phases[p]
+}
+
+// TickObserver receives the metrics of the ticks.
+// The methods are called from the goroutine that calls Engine.Tick
+type TickObserver interface {
+	// OnPhase is called after each phase finished
+	OnPhase(phase TickPhase, d time.Duration)
+	// OnTick is called after the tick finished, stats must not be retained
+	OnTick(stats *TickStats)
+}
+
+// tickTracer measures the phases of a tick, and annotates them with runtime/trace regions
+type tickTracer struct {
+	e        *Engine
+	ctx      context.Context
+	task     *trace.Task
+	observer TickObserver
+	stats    TickStats
+	start    time.Time
+	last     time.Time
+	region   *trace.Region
+}
+
+func (e *Engine) startTickTrace(ctx context.Context, dt time.Duration) (t *tickTracer) {
+	t = &e.tracer
+	t.e = e
+	t.ctx, t.task = trace.NewTask(ctx, "molecular.Tick")
+	t.observer = e.cfg.TickObserver
+	if t.observer != nil {
+		t.stats.DT = dt
+		t.stats.Phases = [tickPhaseCount]time.Duration{}
+		t.stats.WaveObjects = t.stats.WaveObjects[:0]
+		e.RLock()
+		t.stats.Objects = len(e.objects)
+		e.RUnlock()
+		t.start = time.Now()
+		t.last = t.start
+	}
+	return
+}
+
+func (t *tickTracer) startPhase(p TickPhase) {
+	t.region = trace.StartRegion(t.ctx, p.String())
+}
+
+func (t *tickTracer) endPhase(p TickPhase) {
+	t.region.End()
+	t.region = nil
+	if t.observer == nil {
+		return
+	}
+	now := time.Now()
+	d := now.Sub(t.last)
+	t.last = now
+	t.stats.Phases[p] = d
+	if p == PhaseEvents {
+		t.e.RLock()
+		t.stats.EventWaves = len(t.e.events)
+		t.stats.HeavyEvents = 0
+		for _, w := range t.e.events {
+			if w.heavy {
+				t.stats.HeavyEvents++
+			}
+			t.stats.WaveObjects = append(t.stats.WaveObjects, w.touched)
+		}
+		t.e.RUnlock()
+	}
+	t.observer.OnPhase(p, d)
+}
+
+func (t *tickTracer) end() {
+	t.task.End()
+	t.task = nil
+	t.ctx = nil
+	if t.observer == nil {
+		return
+	}
+	t.stats.Duration = t.last.Sub(t.start)
+	t.stats.Pools = PoolStatistics()
+	t.observer.OnTick(&t.stats)
+}
+
+// ExpvarObserver is a TickObserver that publishes the metrics with expvar
+type ExpvarObserver struct {
+	m *expvar.Map
+}
+
+var _ TickObserver = (*ExpvarObserver)(nil)
+
+// NewExpvarObserver publishes an expvar.Map with the name.
+// Like expvar.Publish, it panics if the name is already registered
+func NewExpvarObserver(name string) *ExpvarObserver {
+	return &ExpvarObserver{
+		m: expvar.NewMap(name),
+	}
+}
+
+// Map returns the published expvar.Map
+func (o *ExpvarObserver) Map() *expvar.Map {
+	return o.m
+}
+
+func (o *ExpvarObserver) OnPhase(phase TickPhase, d time.Duration) {
+	o.m.Add("phase."+phase.String()+".ns", d.Nanoseconds())
+}
+
+func (o *ExpvarObserver) OnTick(stats *TickStats) {
+	o.m.Add("ticks", 1)
+	o.m.Add("tick.ns", stats.Duration.Nanoseconds())
+	setExpvarInt(o.m, "objects", (int64)(stats.Objects))
+	setExpvarInt(o.m, "events", (int64)(stats.EventWaves))
+	setExpvarInt(o.m, "events.heavy", (int64)(stats.HeavyEvents))
+	var touched int
+	for _, n := range stats.WaveObjects {
+		touched += n
+	}
+	o.m.Add("events.touched", (int64)(touched))
+	for _, p := range stats.Pools {
+		setExpvarFloat(o.m, "pool."+p.Name+".hitrate", p.HitRate())
+	}
+}
+
+func setExpvarInt(m *expvar.Map, key string, v int64) {
+	if i, ok := m.Get(key).(*expvar.Int); ok {
+		i.Set(v)
+		return
+	}
+	i := new(expvar.Int)
+	i.Set(v)
+	m.Set(key, i)
+}
+
+func setExpvarFloat(m *expvar.Map, key string, v float64) {
+	if f, ok := m.Get(key).(*expvar.Float); ok {
+		f.Set(v)
+		return
+	}
+	f := new(expvar.Float)
+	f.Set(v)
+	m.Set(key, f)
+}
@@ -0,0 +1,104 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+type testTickObserver struct {
+	phases []TickPhase
+	ticks  int
+	stats  TickStats
+}
+
+func (o *testTickObserver) OnPhase(phase TickPhase, d time.Duration) {
+	o.phases = append(o.phases, phase)
+}
+
+func (o *testTickObserver) OnTick(stats *TickStats) {
+	o.ticks++
+	o.stats = *stats
+}
+
+func TestTickObserver(t *testing.T) {
+	obs := new(testTickObserver)
+	e := NewEngine(Config{
+		TickObserver: obs,
+	})
+	for i := 0; i < 3; i++ {
+		e.NewObject(ManMadeObj, nil, Vec3{X: (float64)(i * 10)}, func(o *Object) {
+			o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		})
+	}
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	if obs.ticks != 2 {
+		t.Fatalf("Expect 2 ticks observed, got %d", obs.ticks)
+	}
+	expect := []TickPhase{PhaseObjects, PhaseEvents, PhaseSync, PhaseBreak}
+	if len(obs.phases) != len(expect)*2 {
+		t.Fatalf("Expect phases %v twice, got %v", expect, obs.phases)
+	}
+	for i, p := range obs.phases {
+		if p != expect[i%len(expect)] {
+			t.Fatalf("Expect phases %v twice, got %v", expect, obs.phases)
+		}
+	}
+	s := obs.stats
+	if s.Objects != 3 || s.DT != time.Millisecond || s.EventWaves != 0 {
+		t.Errorf("Unexpected tick stats %+v", s)
+	}
+	var sum time.Duration
+	for _, d := range s.Phases {
+		sum += d
+	}
+	if sum != s.Duration {
+		t.Errorf("Expect tick duration %v equals to the sum of phases %v", s.Duration, sum)
+	}
+	if len(s.Pools) == 0 {
+		t.Errorf("Expect pool statistics")
+	}
+	for _, p := range s.Pools {
+		if r := p.HitRate(); r < 0 || r > 1 {
+			t.Errorf("Expect hit rate of pool %s in [0, 1], got %v", p.Name, r)
+		}
+	}
+}
+
+func TestUnknownEnumString(t *testing.T) {
+	for _, d := range []struct {
+		v      fmt.Stringer
+		expect string
+	}{
+		{TickPhase(100), "TickPhase(100)"},
+		{SimMode(100), "SimMode(100)"},
+		{ObjType(100), "ObjType(100)"},
+		{ValidationPolicy(100), "ValidationPolicy(100)"},
+		{HistoryMapping(100), "HistoryMapping(100)"},
+		{JointType(100), "JointType(100)"},
+		{Integrator(100), "Integrator(100)"},
+	} {
+		if s := d.v.String(); s != d.expect {
+			t.Errorf("Expect %q, got %q", d.expect, s)
+		}
+	}
+}
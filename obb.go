@@ -0,0 +1,204 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+)
+
+// OBB is an oriented bounding box
+type OBB struct {
+	C    Vec3    // Center
+	H    Vec3    // Half extents
+	Axes [3]Vec3 // The unit local axes
+}
+
+// NewOBB creates an OBB which local axes are rotated by the angles
+func NewOBB(center, half, angle Vec3) *OBB {
+	return &OBB{
+		C: center,
+		H: half.Abs(),
+		Axes: [3]Vec3{
+			UnitX.RotatedXYZ(angle),
+			UnitY.RotatedXYZ(angle),
+			UnitZ.RotatedXYZ(angle),
+		},
+	}
+}
+
+// NewOBBFromCube creates an OBB from the Cube which is rotated by the angles around the pivot,
+// and then moved by the offset
+func NewOBBFromCube(c *Cube, offset, pivot, angle Vec3) *OBB {
+	center := c.Center().Subbed(pivot).RotatedXYZ(angle).Added(pivot).Added(offset)
+	return NewOBB(center, c.S.ScaledN(0.5), angle)
+}
+
+func (b *OBB) String() string {
+	return "OBB(center=" + b.C.String() + ", half=" + b.H.String() +
+		", axes=[" + b.Axes[0].String() + ", " + b.Axes[1].String() + ", " + b.Axes[2].String() + "])"
+}
+
+func (b *OBB) half(i int) float64 {
+	switch i {
+	case 0:
+		return b.H.X
+	case 1:
+		return b.H.Y
+	default:
+		return b.H.Z
+	}
+}
+
+// Corners returns the 8 corners of the OBB
+func (b *OBB) Corners() (corners [8]Vec3) {
+	x, y, z := b.Axes[0].ScaledN(b.H.X), b.Axes[1].ScaledN(b.H.Y), b.Axes[2].ScaledN(b.H.Z)
+	for i := range corners {
+		p := b.C
+		if i&1 == 0 {
+			p.Sub(x)
+		} else {
+			p.Add(x)
+		}
+		if i&2 == 0 {
+			p.Sub(y)
+		} else {
+			p.Add(y)
+		}
+		if i&4 == 0 {
+			p.Sub(z)
+		} else {
+			p.Add(z)
+		}
+		corners[i] = p
+	}
+	return
+}
+
+// Support returns the corner of the OBB that is farthest along the direction
+func (b *OBB) Support(dir Vec3) Vec3 {
+	p := b.C
+	for i, a := range b.Axes {
+		if d := dir.Dot(a); d > 0 {
+			p.Add(a.ScaledN(b.half(i)))
+		} else if d < 0 {
+			p.Sub(a.ScaledN(b.half(i)))
+		}
+	}
+	return p
+}
+
+// Bounds returns the axis-aligned bounding box of the OBB
+func (b *OBB) Bounds() *Cube {
+	half := b.Axes[0].Abs().ScaledN(b.H.X).
+		Added(b.Axes[1].Abs().ScaledN(b.H.Y)).
+		Added(b.Axes[2].Abs().ScaledN(b.H.Z))
+	return &Cube{
+		P: b.C.Subbed(half),
+		S: half.ScaledN(2),
+	}
+}
+
+// Contains reports whether the point is inside the OBB
+func (b *OBB) Contains(p Vec3) bool {
+	d := p.Subbed(b.C)
+	for i, a := range b.Axes {
+		if math.Abs(d.Dot(a)) > b.half(i)+faceEpsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// ClosestPoint returns the point on or inside the OBB that is closest to p
+func (b *OBB) ClosestPoint(p Vec3) Vec3 {
+	d := p.Subbed(b.C)
+	q := b.C
+	for i, a := range b.Axes {
+		h := b.half(i)
+		q.Add(a.ScaledN(math.Max(-h, math.Min(h, d.Dot(a)))))
+	}
+	return q
+}
+
+// Overlap will return if the two OBB overlapped or touched,
+// using the separating axis theorem
+func (b *OBB) Overlap(x *OBB) bool {
+	var r, absR [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i][j] = b.Axes[i].Dot(x.Axes[j])
+			// add an epsilon to avoid the null cross product when two edges are parallel
+			absR[i][j] = math.Abs(r[i][j]) + faceEpsilon
+		}
+	}
+	d := x.C.Subbed(b.C)
+	t := [3]float64{d.Dot(b.Axes[0]), d.Dot(b.Axes[1]), d.Dot(b.Axes[2])}
+	bh := [3]float64{b.H.X, b.H.Y, b.H.Z}
+	xh := [3]float64{x.H.X, x.H.Y, x.H.Z}
+
+	// the axes of b
+	for i := 0; i < 3; i++ {
+		rx := xh[0]*absR[i][0] + xh[1]*absR[i][1] + xh[2]*absR[i][2]
+		if math.Abs(t[i]) > bh[i]+rx+faceEpsilon {
+			return false
+		}
+	}
+	// the axes of x
+	for j := 0; j < 3; j++ {
+		rb := bh[0]*absR[0][j] + bh[1]*absR[1][j] + bh[2]*absR[2][j]
+		if math.Abs(t[0]*r[0][j]+t[1]*r[1][j]+t[2]*r[2][j]) > rb+xh[j]+faceEpsilon {
+			return false
+		}
+	}
+	// the cross products of the axes
+	for i := 0; i < 3; i++ {
+		i1, i2 := (i+1)%3, (i+2)%3
+		for j := 0; j < 3; j++ {
+			j1, j2 := (j+1)%3, (j+2)%3
+			rb := bh[i1]*absR[i2][j] + bh[i2]*absR[i1][j]
+			rx := xh[j1]*absR[i][j2] + xh[j2]*absR[i][j1]
+			if math.Abs(t[i2]*r[i1][j]-t[i1]*r[i2][j]) > rb+rx+faceEpsilon {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// OverlapCube will return if the OBB overlapped or touched with the axis-aligned Cube
+func (b *OBB) OverlapCube(c *Cube) bool {
+	return b.Overlap(&OBB{
+		C:    c.Center(),
+		H:    c.S.ScaledN(0.5),
+		Axes: [3]Vec3{UnitX, UnitY, UnitZ},
+	})
+}
+
+// BlockOBB returns the OBB of the block in the object's anchor space, which applied the object's orientation
+func (o *Object) BlockOBB(b Block) *OBB {
+	o.RLock()
+	defer o.RUnlock()
+	return NewOBBFromCube(b.Outline(), o.pos, o.gcenter, o.angle)
+}
+
+// AbsBlockOBB returns the OBB of the block in absolute space
+func (o *Object) AbsBlockOBB(b Block) *OBB {
+	abs := o.AbsPos()
+	o.RLock()
+	defer o.RUnlock()
+	return NewOBBFromCube(b.Outline(), abs, o.gcenter, o.angle)
+}
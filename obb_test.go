@@ -0,0 +1,66 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func TestOBBOverlap(t *testing.T) {
+	type T struct {
+		A, B    *OBB
+		Overlap bool
+	}
+	half := OneVec.ScaledN(0.5)
+	datas := []T{
+		{NewOBB(ZeroVec, half, ZeroVec), NewOBB(ZeroVec, half, ZeroVec), true},
+		{NewOBB(ZeroVec, half, ZeroVec), NewOBB(UnitX, half, ZeroVec), true},
+		{NewOBB(ZeroVec, half, ZeroVec), NewOBB(Vec3{1.1, 0, 0}, half, ZeroVec), false},
+		// the corner of a 45° rotated cube reaches 0.5 + sqrt(2) / 2
+		{NewOBB(ZeroVec, half, ZeroVec), NewOBB(Vec3{1.2, 0, 0}, half, Vec3{0, 0, math.Pi / 4}), true},
+		{NewOBB(ZeroVec, half, ZeroVec), NewOBB(Vec3{1.3, 0, 0}, half, Vec3{0, 0, math.Pi / 4}), false},
+		{NewOBB(ZeroVec, half, Vec3{math.Pi / 4, 0, 0}), NewOBB(Vec3{0, 1.2, 0}, half, Vec3{0, 0, math.Pi / 4}), true},
+		{NewOBB(ZeroVec, half, Vec3{math.Pi / 4, 0, 0}), NewOBB(Vec3{0, 1.6, 0}, half, Vec3{0, 0, math.Pi / 4}), false},
+	}
+	for _, d := range datas {
+		if o := d.A.Overlap(d.B); o != d.Overlap {
+			t.Errorf("Incorrect overlap result %v for %v & %v, expect %v", o, d.A, d.B, d.Overlap)
+		}
+		if o := d.B.Overlap(d.A); o != d.Overlap {
+			t.Errorf("Incorrect overlap result %v for %v & %v, expect %v", o, d.B, d.A, d.Overlap)
+		}
+	}
+	if !NewOBB(ZeroVec, half, Vec3{0, 0, math.Pi / 4}).OverlapCube(NewCube(Vec3{0.6, -0.5, -0.5}, OneVec)) {
+		t.Errorf("Expect rotated OBB overlap with the cube")
+	}
+}
+
+func TestOBBClosestPoint(t *testing.T) {
+	b := NewOBB(ZeroVec, OneVec, Vec3{0, 0, math.Pi / 4})
+	if p := b.ClosestPoint(ZeroVec); !vecNear(p, ZeroVec) {
+		t.Errorf("Expect inside point unchanged, got %v", p)
+	}
+	if p := b.ClosestPoint(Vec3{0, 10, 0}); math.Abs(p.Y-math.Sqrt2) > 1e-9 || math.Abs(p.X) > 1e-9 {
+		t.Errorf("Expect closest point at the corner (0, √2, 0), got %v", p)
+	}
+	if !b.Contains(Vec3{0, 1.4, 0}) || b.Contains(Vec3{1, 1, 0}) {
+		t.Errorf("Incorrect Contains result")
+	}
+}
@@ -43,22 +43,24 @@ func (t ObjType) String() string {
 	case LivingObj:
 		return "living"
 	default:
-		panic("Unknown object type value")
+		return fmt.Sprintf("ObjType(%d)", t)
 	}
 }
 
 type objStatus struct {
-	system    *System
-	anchor    *Object
-	children  []*Object
-	blocks    []Block // TODO: sort or index blocks
-	gcenter   Vec3    // the gravity center
-	mass      float64 // the cached mass
-	pos       Vec3    // the position relative to the anchor
-	tickForce Vec3
-	velocity  Vec3
-	angle     Vec3
-	headVel   Vec3
+	system     *System
+	anchor     *Object
+	children   []*Object
+	blocks     []Block
+	gcenter    Vec3    // the gravity center
+	bounds     Cube    // the bounding box of the blocks
+	mass       float64 // the cached mass
+	pos        Vec3    // the position relative to the anchor
+	tickForce  Vec3
+	tickTorque Vec3
+	velocity   Vec3
+	angle      Vec3
+	headVel    Vec3
 }
 
 func makeObjStatus() objStatus {
@@ -71,11 +73,14 @@ func (s *objStatus) from(a *objStatus) {
 	s.children = append(s.children[:0], a.children...)
 	s.blocks = append(s.blocks[:0], a.blocks...)
 	s.gcenter = a.gcenter
+	s.bounds = a.bounds
 	s.mass = a.mass
 	s.angle = a.angle
 	s.pos = a.pos
 	s.tickForce = a.tickForce
+	s.tickTorque = a.tickTorque
 	s.velocity = a.velocity
+	s.headVel = a.headVel
 }
 
 func (s *objStatus) clone() (a objStatus) {
@@ -94,13 +99,37 @@ type Object struct {
 	typ   ObjType
 	objStatus
 	gfield           *GravityField
-	historyGFields   []*GravityField
+	ghistory         GravityHistory
+	historyGFields   []gravitySample
 	gfieldUpdateMask Bitset
 	gfieldUpdateCd   time.Duration
+	historyStates    []stateSample
+	stateUpdateMask  Bitset
+	stateUpdateCd    time.Duration
+	index            *blockIndex // the index of the current blocks
 
 	nextMux    sync.RWMutex
 	nextStatus objStatus
 	nextCalls  []func()
+
+	damages    map[Block]float64 // the accumulated damages of the blocks
+	blockTemps map[Block]float64 // the block temperatures of the last tick
+	broken     []brokenBlock     // the blocks that broke during the last sync
+	needSplit  atomic.Bool       // whether blocks are removed and the connectivity need to be checked
+
+	tidalCache  []Vec3
+	tidalStress float64
+	rocheBroken atomic.Bool // whether the object is a fragment of a Roche breakup
+
+	ccd atomic.Bool // whether continuous collision detection is always enabled
+
+	simMode      atomic.Uint32
+	rails        Trajectory
+	railsTime    float64 // the elapsed proper time of the anchor on the rails
+	sleepTicks   int     // the count of the ticks that the object is almost still
+	sleepPending bool    // whether the object will fall asleep after sync
+
+	invalid *InvalidStateEvent // the invalid state found during the last sync
 }
 
 func (e *Engine) newAndPutObject(id uuid.UUID, stat objStatus) (o *Object) {
@@ -113,8 +142,11 @@ func (e *Engine) newAndPutObject(id uuid.UUID, stat objStatus) (o *Object) {
 		objStatus:  stat,
 		nextStatus: stat.clone(),
 
-		gfield: NewGravityField(ZeroVec, 0, 0),
-		historyGFields: make([]*GravityField, 16), // TODO: maybe dynamically set a suitable history cache?
+		gfield:         NewGravityField(stat.gcenter, stat.mass, 0),
+		ghistory:       e.cfg.GravityHistory,
+		historyGFields: make([]gravitySample, e.cfg.GravityHistory.Length),
+		historyStates:  make([]stateSample, e.cfg.GravityHistory.Length),
+		index:          newBlockIndex(e.cfg.BlockCellSize),
 	}
 	if _, ok := e.objects[id]; ok {
 		panic("molecular.Engine: Object id " + id.String() + " is already exists")
@@ -123,6 +155,7 @@ func (e *Engine) newAndPutObject(id uuid.UUID, stat objStatus) (o *Object) {
 
 	for _, b := range stat.blocks {
 		b.SetObject(o)
+		o.index.Add(b)
 	}
 	return
 }
@@ -205,6 +238,20 @@ func (o *Object) SetAngle(angle Vec3) {
 	o.nextStatus.angle = angle
 }
 
+// WorldFacing resolves the Facing in the object's local space to the closest Facing in the anchor space
+func (o *Object) WorldFacing(f Facing) Facing {
+	o.RLock()
+	defer o.RUnlock()
+	return FacingOf(f.Normal().RotatedXYZ(o.angle))
+}
+
+// LocalFacing resolves the Facing in the anchor space to the closest Facing in the object's local space
+func (o *Object) LocalFacing(f Facing) Facing {
+	o.RLock()
+	defer o.RUnlock()
+	return FacingOf(f.Normal().UnrotatedXYZ(o.angle))
+}
+
 func (o *Object) Velocity() Vec3 {
 	o.RLock()
 	defer o.RUnlock()
@@ -396,7 +443,7 @@ func (o *Object) AbsPosAndAnchorLocked() (p Vec3, m *Object) {
 	return
 }
 
-var objSetPool = newObjPool[set[*Object]]()
+var objSetPool = newObjPool[set[*Object]]("objSet")
 
 // RelPos returns the relative position of the passed object about this object
 // To be clear, return the displacement from o to a (a.pos - o.pos)
@@ -458,14 +505,18 @@ func (o *Object) RotatePos(p *Vec3) *Vec3 {
 }
 
 func (o *Object) AbsVelocity() (v Vec3) {
-	v, m := o.velocity, o
-	for m.anchor != nil {
-		m = m.anchor
+	return o.e.absVelocityFrom(o.velocity, o.anchor)
+}
+
+// absVelocityFrom composes the velocity relative to the anchor with the velocities along the anchor chain,
+// so it's in the main anchor space
+func (e *Engine) absVelocityFrom(v Vec3, anchor *Object) Vec3 {
+	for m := anchor; m != nil; m = m.anchor {
 		v.
-			ScaleN(o.e.ReLorentzFactorSq(m.velocity.SqLen())).
+			ScaleN(e.ReLorentzFactorSq(m.velocity.SqLen())).
 			Add(m.velocity)
 	}
-	return
+	return v
 }
 
 func (o *Object) Blocks() []Block {
@@ -477,6 +528,7 @@ func (o *Object) SetBlocks(blocks []Block) {
 		for _, b := range blocks {
 			b.SetObject(o)
 		}
+		o.index.Reset(blocks)
 	})
 	o.nextStatus.blocks = blocks
 }
@@ -485,21 +537,32 @@ func (o *Object) AddBlock(blocks ...Block) {
 	o.nextCalls = append(o.nextCalls, func() {
 		for _, b := range blocks {
 			b.SetObject(o)
+			o.index.Add(b)
 		}
 	})
 	o.nextStatus.blocks = append(o.nextStatus.blocks, blocks...)
 }
 
 func (o *Object) RemoveBlock(target Block) {
+	o.removeBlock(target)
+}
+
+// removeBlock removes the block from the next status, and reports whether the block was found
+func (o *Object) removeBlock(target Block) bool {
 	blocks := o.nextStatus.blocks
 	last := len(blocks) - 1
 	for i, b := range blocks {
 		if b == target {
 			blocks[i] = blocks[last]
 			o.nextStatus.blocks = blocks[:last]
-			return
+			o.nextCalls = append(o.nextCalls, func() {
+				o.index.Remove(target)
+			})
+			o.needSplit.Store(true)
+			return true
 		}
 	}
+	return false
 }
 
 // TickForce returns the force vector that can be edit during a tick.
@@ -512,12 +575,6 @@ func (o *Object) SetRadius(radius float64) {
 	o.gfield.SetRadius(radius)
 }
 
-func (o *Object) FillGfields() {
-	for i := 0; i < len(o.historyGFields); i++ {
-		o.historyGFields[i] = o.gfield.Clone()
-	}
-}
-
 func (o *Object) Mass() (mass float64) {
 	o.RLock()
 	defer o.RUnlock()
@@ -557,27 +614,6 @@ func (o *Object) GravityField() *GravityField {
 	return o.gfield
 }
 
-// GravityFieldAt will returns the correct history gravity field by position.
-// argument pos is the position relative to the zero position of this object
-func (o *Object)GravityFieldAt(pos Vec3) Vec3 {
-	if o.gfield == nil {
-		return ZeroVec
-	}
-	radius := o.gfield.Radius()
-	if pos.SqLen() < radius * radius * 4 {
-		return o.gfield.FieldAt(pos)
-	}
-	i := math.Ilogb(pos.Subbed(o.gfield.Pos()).SqLen() / cSq) / 2
-	if i < 0 {
-		return o.gfield.FieldAt(pos)
-	}
-	if i >= len(o.historyGFields) {
-		return ZeroVec
-	}
-	g := o.historyGFields[i]
-	return g.FieldAt(pos)
-}
-
 func (o *Object) reLorentzFactor() float64 {
 	if o.anchor == nil {
 		return 1
@@ -589,6 +625,16 @@ func (o *Object) ProperTime(dt time.Duration) float64 {
 	return dt.Seconds() / o.reLorentzFactor()
 }
 
+// gravityAtLocked returns the gravity acceleration caused by the anchor and the siblings.
+// argument pos is the position relative to the zero position of the anchor
+func (o *Object) gravityAtLocked(pos Vec3) (acc Vec3) {
+	acc = o.anchor.GravityFieldAt(pos)
+	o.forEachSibling(func(s *Object) {
+		acc.Add(s.GravityFieldAt(pos.Subbed(s.pos)))
+	})
+	return
+}
+
 func (o *Object) tick(dt time.Duration) {
 	o.RLock()
 	defer o.RUnlock()
@@ -604,27 +650,17 @@ func (o *Object) tick(dt time.Duration) {
 
 	// reset the state
 	o.tickForce = ZeroVec
+	o.tickTorque = ZeroVec
 
-	// tick blocks
-	gcenter := ZeroVec
-	mass := 0.0
-	for _, b := range o.blocks {
-		b.Tick(pt)
-		l := b.Outline()
-		m := b.Mass()
-		mass += m
-		c := l.Center()
-		if mass == 0 {
-			gcenter = c
-		} else {
-			gcenter.Add(c.Subbed(gcenter).ScaledN(m / mass))
-		}
+	gcenter, mass := o.tickBlocksLocked(pt)
+	if mass > 0 {
+		o.tickThrustersLocked(pt)
 	}
-	if mass < 0 {
-		mass = 0
+	if o.mass > 0 && mass > 0 && gcenter != o.gcenter {
+		// keep the blocks stay still when the rotate pivot moved
+		o.nextStatus.pos.Add(pivotOffset(o.gcenter, o.angle)).Sub(pivotOffset(gcenter, o.angle))
+		o.nextStatus.velocity.Add(o.headVel.Cross(gcenter.Subbed(o.gcenter).RotatedXYZ(o.angle)))
 	}
-	o.nextStatus.mass = mass
-	o.nextStatus.gcenter = gcenter
 	if mass > 0 { // apply gravities
 		pos := o.pos
 		var (
@@ -633,13 +669,12 @@ func (o *Object) tick(dt time.Duration) {
 			smallestO *Object
 		)
 		if o.anchor != nil {
-			vel = o.anchor.GravityFieldAt(pos)
+			if o.e.cfg.TidalForces {
+				vel = o.tidalAccLocked(gcenter, mass, pt)
+			} else {
+				vel = o.gravityAtLocked(pos)
+			}
 			vel.ScaleN(dt.Seconds())
-			o.forEachSibling(func(s *Object){
-				f := s.GravityFieldAt(pos.Subbed(s.pos))
-				f.ScaleN(dt.Seconds())
-				vel.Add(f)
-			})
 		}
 		if smallestO != nil {
 			println(smallestL)
@@ -649,6 +684,10 @@ func (o *Object) tick(dt time.Duration) {
 	}
 	if mass > 0 {
 		o.nextStatus.velocity.Add(o.tickForce.ScaledN(pt / mass))
+		if !o.tickTorque.IsZero() {
+			acc := inertiaAccel(blocksInertia(o.blocks, gcenter), o.angle, o.tickTorque)
+			o.nextStatus.headVel.Add(acc.ScaledN(pt))
+		}
 	}
 
 	{ // calculate the new position and angle
@@ -664,44 +703,81 @@ func (o *Object) tick(dt time.Duration) {
 		av.ScaleN(apt / 2)
 		o.nextStatus.angle.Add(av).ModN(math.Pi)
 	}
+	o.tickSleepLocked(pt)
+}
+
+// tickBlocksLocked ticks all the blocks, and saves their gravity center, mass and bounds
+// into the next status. The blocks that changed their outlines will be re-indexed during sync
+func (o *Object) tickBlocksLocked(pt float64) (gcenter Vec3, mass float64) {
+	var bounds Cube
+	first := true
+	var stale []Block
+	for _, b := range o.blocks {
+		l, m, ok := o.tickBlockLocked(b, pt)
+		if !ok {
+			continue
+		}
+		if o.index.Stale(b, l) {
+			stale = append(stale, b)
+		}
+		if first {
+			bounds = *l
+			first = false
+		} else {
+			bounds.Extend(l)
+		}
+		mass += m
+		c := l.Center()
+		if mass == 0 {
+			gcenter = c
+		} else {
+			gcenter.Add(c.Subbed(gcenter).ScaledN(m / mass))
+		}
+	}
+	if mass < 0 {
+		mass = 0
+	}
+	o.nextStatus.mass = mass
+	o.nextStatus.gcenter = gcenter
+	o.nextStatus.bounds = bounds
+	if len(stale) > 0 {
+		// the outlines are changed by the blocks, re-index them when the status is saved
+		o.nextCalls = append(o.nextCalls, func() {
+			for _, b := range stale {
+				o.index.Update(b)
+			}
+		})
+	}
+	return
+}
+
+// tickBlockLocked ticks the block and returns its outline and mass.
+// ok is false if the block panicked
+func (o *Object) tickBlockLocked(b Block, pt float64) (l *Cube, m float64, ok bool) {
+	defer o.e.recoverTick(PhaseObjects, o, b)
+	b.Tick(pt)
+	if tb, ok := b.(ThermalBlock); ok {
+		o.tickThermalStressLocked(tb)
+	}
+	return b.Outline(), b.Mass(), true
 }
 
 func (o *Object) saveStatus(dt time.Duration) {
 	o.Lock()
 	defer o.Unlock()
-	o.nextMux.RLock()
-	defer o.nextMux.RUnlock()
-
-	posdiff := o.nextStatus.pos.Subbed(o.objStatus.pos)
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
 
+	o.breakBlocksLocked()
+	o.syncSleepLocked()
 	for _, cb := range o.nextCalls {
 		cb()
 	}
 	o.nextCalls = o.nextCalls[:0]
+	o.validateLocked()
 	o.objStatus.from(&o.nextStatus)
 
 	o.gfield.SetPos(o.gcenter)
 	o.gfield.SetMass(o.mass)
-	if o.gfieldUpdateCd -= dt; o.gfieldUpdateCd < 0 {
-		o.gfieldUpdateCd = time.Second
-
-		for _, g := range o.historyGFields {
-			if g != nil {
-				g.pos.Add(posdiff)
-			}
-		}
-
-		// update history gravity fields
-		last := o.historyGFields[0]
-		o.historyGFields[0] = o.gfield.Clone()
-		for i := 1; i < len(o.historyGFields); i++ {
-			if o.gfieldUpdateMask.Flip(i) {
-				if last != nil {
-					gravityFieldPool.Put(last)
-				}
-				break
-			}
-			last, o.historyGFields[i] = o.historyGFields[i], last
-		}
-	}
+	o.tickGravityHistoryLocked(dt)
 }
@@ -19,6 +19,7 @@ package molecular_test
 import (
 	"math"
 	"testing"
+	"time"
 
 	. "github.com/LiterMC/molecular"
 )
@@ -40,3 +41,228 @@ func TestObjectAbsPosRotate(t *testing.T) {
 		t.Errorf("o3 assert failed: %v", o3.AbsPos())
 	}
 }
+
+func TestObjectSplit(t *testing.T) {
+	var splits [][]*Object
+	e := NewEngine(Config{
+		OnObjectSplit: func(o *Object, parts []*Object) {
+			splits = append(splits, parts)
+		},
+	})
+	b1 := newTestBlock(ZeroVec, 2, nil)
+	b2 := newTestBlock(UnitX, 1, nil)
+	b3 := newTestBlock(UnitX.ScaledN(2), 1, nil)
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(b1, b2, b3)
+		o.SetHeadingVel(UnitZ)
+	})
+	e.Tick(time.Millisecond)
+	if islands := o.Islands(); len(islands) != 1 {
+		t.Fatalf("Expect 1 island, got %d", len(islands))
+	}
+	e.Tick(time.Millisecond)
+
+	o.RemoveBlock(b2)
+	e.Tick(time.Millisecond)
+	if len(splits) != 1 || len(splits[0]) != 1 {
+		t.Fatalf("Expect object split into 1 new part, got %v", splits)
+	}
+	part := splits[0][0]
+	if blocks := o.Blocks(); len(blocks) != 1 || blocks[0] != b1 {
+		t.Errorf("Expect heavier block stay in the object, got %v", blocks)
+	}
+	if part.Anchor() != o.Anchor() {
+		t.Errorf("Expect the part has the same anchor")
+	}
+	if v := part.Velocity(); v.Y <= 0 {
+		t.Errorf("Expect the part inherit tangential velocity, got %v", v)
+	}
+	e.Tick(time.Millisecond)
+	if b3.obj != part {
+		t.Errorf("Expect block moved to the new part")
+	}
+}
+
+func TestObjectMerge(t *testing.T) {
+	e := NewEngine(Config{})
+	b1 := newTestBlock(ZeroVec, 1, nil)
+	b2 := newTestBlock(ZeroVec, 1, nil)
+	o1 := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(b1)
+		o.SetVelocity(Vec3{0.2, 0, 0})
+	})
+	o2 := e.NewObject(ManMadeObj, nil, UnitX, func(o *Object) {
+		o.AddBlock(b2)
+	})
+	child := e.NewObject(ManMadeObj, o2, UnitY)
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	if !o1.TryMerge(o2) {
+		t.Fatalf("Expect touching objects merged")
+	}
+	if e.GetObject(o2.Id()) != nil {
+		t.Errorf("Expect absorbed object removed from engine")
+	}
+	if b2.obj != o1 {
+		t.Errorf("Expect block moved to the merged object")
+	}
+	if p := b2.Outline().Pos(); math.Abs(p.X-1) > 1e-3 || math.Abs(p.Y) > 1e-9 || math.Abs(p.Z) > 1e-9 {
+		t.Errorf("Expect block transformed to (1, 0, 0), got %v", p)
+	}
+	if v := o1.Velocity(); math.Abs(v.X-0.1) > 1e-9 {
+		t.Errorf("Expect momentum conserved velocity (0.1, 0, 0), got %v", v)
+	}
+	e.Tick(time.Millisecond)
+	if child.Anchor() != o1 {
+		t.Errorf("Expect child attached to the merged object")
+	}
+	if p := child.Pos(); math.Abs(p.X-1) > 1e-3 || math.Abs(p.Y-1) > 1e-3 {
+		t.Errorf("Expect child position (1, 1, 0), got %v", p)
+	}
+}
+
+func TestObjectMergeRotated(t *testing.T) {
+	for _, c := range []struct {
+		angle  float64
+		expect Vec3
+	}{
+		{math.Pi / 6, Vec3{1, 2, 1}},
+		{math.Pi / 2, Vec3{2, 1, 1}},
+		{math.Pi / 3, Vec3{2, 1, 1}},
+		{math.Pi * 5 / 6, Vec3{1, 2, 1}},
+	} {
+		e := NewEngine(Config{})
+		o1 := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+			o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		})
+		b := &testBlock{mass: 1, outline: NewCube(ZeroVec, Vec3{1, 2, 1})}
+		o2 := e.NewObject(ManMadeObj, nil, UnitX, func(o *Object) {
+			o.AddBlock(b)
+			o.SetAngle(Vec3{Z: c.angle})
+		})
+		e.Tick(time.Millisecond)
+		e.Tick(time.Millisecond)
+		o1.Merge(o2)
+		if s := b.Outline().S; s.Subbed(c.expect).Len() > 1e-9 {
+			t.Errorf("Expect block merged at %v rad has size %v, got %v", c.angle, c.expect, s)
+		}
+	}
+}
+
+func TestObjectBlockIndex(t *testing.T) {
+	e := NewEngine(Config{})
+	b1 := newTestBlock(ZeroVec, 1, nil)
+	b2 := newTestBlock(UnitX, 1, nil)
+	b3 := newTestBlock(Vec3{5, 5, 5}, 1, nil)
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(b1, b2, b3)
+		// fill more cells, so the queries are not falling back to scan all the cells
+		for i := 0; i < 16; i++ {
+			o.AddBlock(newTestBlock(Vec3{10 + (float64)(i), 0, 0}, 1, nil))
+		}
+	})
+	e.Tick(time.Millisecond)
+	if b := o.BlockAt(Vec3{1.5, 0.5, 0.5}); b != b2 {
+		t.Errorf("Expect b2 at (1.5, 0.5, 0.5), got %v", b)
+	}
+	if b := o.BlockAt(Vec3{3, 3, 3}); b != nil {
+		t.Errorf("Expect no block at (3, 3, 3), got %v", b)
+	}
+	if blocks := o.BlocksInBox(&Cube{P: ZeroVec, S: Vec3{2, 1, 1}}); len(blocks) != 2 {
+		t.Errorf("Expect 2 blocks in box, got %v", blocks)
+	}
+	if blocks := o.Neighbours(b1, RIGHT); len(blocks) != 1 || blocks[0] != b2 {
+		t.Errorf("Expect b2 on the right of b1, got %v", blocks)
+	}
+	if blocks := o.Neighbours(b2, LEFT); len(blocks) != 1 || blocks[0] != b1 {
+		t.Errorf("Expect b1 on the left of b2, got %v", blocks)
+	}
+	if blocks := o.BlocksInBox(&Cube{P: Vec3{2, 0, 0}, S: OneVec}); len(blocks) != 1 || blocks[0] != b2 {
+		t.Errorf("Expect b2 touching the box on its left face, got %v", blocks)
+	}
+	o.RemoveBlock(b2)
+	e.Tick(time.Millisecond)
+	if blocks := o.Neighbours(b1, RIGHT); len(blocks) != 0 {
+		t.Errorf("Expect no block on the right of b1, got %v", blocks)
+	}
+}
+
+func TestObjectNeighboursFacing(t *testing.T) {
+	e := NewEngine(Config{})
+	b := newTestBlock(ZeroVec, 1, nil)
+	top := newTestBlock(UnitY, 1, nil)
+	edge := newTestBlock(Vec3{1, 1, 0}, 1, nil)
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(b, top, edge)
+		for i := 0; i < 16; i++ {
+			o.AddBlock(newTestBlock(Vec3{10 + (float64)(i), 0, 0}, 1, nil))
+		}
+	})
+	e.Tick(time.Millisecond)
+	// the block on top shares the TOP face, the other one only touches the edge
+	if blocks := o.Neighbours(b, RIGHT); len(blocks) != 0 {
+		t.Errorf("Expect no block on the right of b, got %v", blocks)
+	}
+	if blocks := o.Neighbours(b, TOP); len(blocks) != 1 || blocks[0] != top {
+		t.Errorf("Expect only the top block on the top of b, got %v", blocks)
+	}
+	if blocks := o.Neighbours(top, RIGHT); len(blocks) != 1 || blocks[0] != edge {
+		t.Errorf("Expect the edge block on the right of the top block, got %v", blocks)
+	}
+}
+
+func TestObjectBlockIndexOutlineChange(t *testing.T) {
+	e := NewEngine(Config{})
+	b := newTestBlock(ZeroVec, 1, nil)
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(b)
+	})
+	e.Tick(time.Millisecond)
+	// the block moves itself inside the object
+	b.outline.P = Vec3{3, 0, 0}
+	e.Tick(time.Millisecond)
+	if a := o.BlockAt(Vec3{3.5, 0.5, 0.5}); a != b {
+		t.Errorf("Expect the block is re-indexed at its new outline, got %v", a)
+	}
+	if a := o.BlockAt(Vec3{0.5, 0.5, 0.5}); a != nil {
+		t.Errorf("Expect no block at the old outline, got %v", a)
+	}
+}
+
+func TestObjectSplitBlocksState(t *testing.T) {
+	e := NewEngine(Config{})
+	b1 := newTestBlock(ZeroVec, 2, nil)
+	b2 := newTestBlock(UnitX.ScaledN(3), 1, nil)
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(b1, b2)
+		o.SetAngle(Vec3{Z: 0.3})
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	worldCenter := func(o *Object, b Block) Vec3 {
+		gc := o.GravityCenter()
+		return o.Pos().Added(gc).Added(b.Outline().Center().Subbed(gc).RotatedXYZ(o.Angle()))
+	}
+	w1, w2 := worldCenter(o, b1), worldCenter(o, b2)
+
+	part := o.SplitBlocks([]Block{b2})
+	if m := o.Mass(); m != 2 {
+		t.Errorf("Expect remaining mass 2 right after split, got %v", m)
+	}
+	if m := part.Mass(); m != 1 {
+		t.Errorf("Expect fragment mass 1 right after split, got %v", m)
+	}
+	if gc, expect := part.GravityCenter(), b2.Outline().Center(); gc != expect {
+		t.Errorf("Expect fragment gravity center %v, got %v", expect, gc)
+	}
+	for i := 0; i < 2; i++ {
+		if p := worldCenter(o, b1); p.Subbed(w1).Len() > 1e-9 {
+			t.Errorf("Expect remaining block stay at %v, got %v", w1, p)
+		}
+		if p := worldCenter(part, b2); p.Subbed(w2).Len() > 1e-9 {
+			t.Errorf("Expect split block stay at %v, got %v", w2, p)
+		}
+		e.Tick(time.Millisecond)
+	}
+}
@@ -0,0 +1,203 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+	"time"
+)
+
+// ObservedState is the state of an object that an observer sees through light
+type ObservedState struct {
+	// Pos is the retarded position relative to the observer,
+	// which is where the target was when the light now arriving left it
+	Pos Vec3
+	// Vel is the velocity of the target relative to the observer
+	Vel Vec3
+	// Delay is the light travel time
+	Delay time.Duration
+	// Direction is the apparent direction of the target after aberration, in the observer's rest frame
+	Direction Vec3
+	// Doppler is the ratio of the observed frequency to the emitted frequency,
+	// less than 1 means redshift
+	Doppler float64
+}
+
+// retardedTime solves |d - u * t| = c * t for the smallest non-negative t,
+// which is the light travel time from a source moving with constant velocity u
+// that is at d relative to the receiver now
+func retardedTime(d, u Vec3) float64 {
+	dSq := d.SqLen()
+	if dSq == 0 {
+		return 0
+	}
+	a := u.SqLen() - cSq
+	if a >= 0 {
+		// the source is not slower than light
+		return math.Sqrt(dSq) / C
+	}
+	b := d.Dot(u)
+	return (b - math.Sqrt(b*b-a*dSq)) / a
+}
+
+func lorentzFactor(beta Vec3) float64 {
+	return 1 / math.Sqrt(math.Max(1-beta.SqLen(), math.SmallestNonzeroFloat64))
+}
+
+// stateSample is a snapshot of the absolute position and velocity of an object
+type stateSample struct {
+	pos, vel Vec3
+	age      time.Duration // the time since the snapshot was taken
+	ok       bool
+}
+
+// recordStates ages the state history of the objects,
+// and takes a snapshot of their absolute states every interval of their GravityHistory
+func (e *Engine) recordStates(dt time.Duration) {
+	e.RLock()
+	defer e.RUnlock()
+
+	// read all the states first, since the absolute states read the anchors
+	type absState struct {
+		o        *Object
+		pos, vel Vec3
+	}
+	states := make([]absState, 0, len(e.objects))
+	for _, o := range e.objects {
+		states = append(states, absState{o, o.AbsPos(), o.AbsVelocity()})
+	}
+	for _, s := range states {
+		s.o.Lock()
+		s.o.tickStateHistoryLocked(dt, s.pos, s.vel)
+		s.o.Unlock()
+	}
+}
+
+func (o *Object) tickStateHistoryLocked(dt time.Duration, pos, vel Vec3) {
+	history := o.historyStates
+	if len(history) == 0 {
+		return
+	}
+	for i := range history {
+		if history[i].ok {
+			history[i].age = addAge(history[i].age, dt)
+		}
+	}
+	if o.stateUpdateCd -= dt; o.stateUpdateCd >= 0 {
+		return
+	}
+	o.stateUpdateCd = o.ghistory.Interval
+	pushHistory(history, &o.stateUpdateMask, o.ghistory.Mapping, stateSample{pos: pos, vel: vel, ok: true})
+}
+
+// pastStates returns the current absolute state followed by the recorded states in increasing ages
+func (o *Object) pastStates() []stateSample {
+	pos, vel := o.AbsPos(), o.AbsVelocity()
+	o.RLock()
+	defer o.RUnlock()
+	states := make([]stateSample, 1, len(o.historyStates)+1)
+	states[0] = stateSample{pos: pos, vel: vel, ok: true}
+	for _, s := range o.historyStates {
+		if s.ok && s.age > states[len(states)-1].age {
+			states = append(states, s)
+		}
+	}
+	return states
+}
+
+// hermiteState interpolates the state between the newer snapshot a and the older snapshot b at the age.
+// The position uses the cubic Hermite spline of the positions and velocities,
+// and the velocity is interpolated linearly
+func hermiteState(a, b *stateSample, age time.Duration) (pos, vel Vec3) {
+	h := (b.age - a.age).Seconds()
+	// s goes from 0 at b to 1 at a, forward in time
+	s := (b.age - age).Seconds() / h
+	s2, s3 := s*s, s*s*s
+	pos = b.pos.ScaledN(2*s3 - 3*s2 + 1).
+		Added(b.vel.ScaledN((s3 - 2*s2 + s) * h)).
+		Added(a.pos.ScaledN(-2*s3 + 3*s2)).
+		Added(a.vel.ScaledN((s3 - s2) * h))
+	vel = b.vel.Added(a.vel.Subbed(b.vel).ScaledN(s))
+	return
+}
+
+// retardedState solves the light-time equation |d + P(t) - P(0)| = c * t against the state history,
+// where d is the current position of the target relative to the receiver, and P(t) is the position t ago.
+// It returns the displacement P(t) - P(0), the velocity at that time, and the light travel time t.
+// Beyond the oldest state, the target is assumed to keep the velocity of that state
+func retardedState(d Vec3, states []stateSample) (disp, vel Vec3, delay float64) {
+	p0 := states[0].pos
+	// f is positive once the light from the age has arrived
+	f := func(age time.Duration, pos Vec3) float64 {
+		return age.Seconds()*C - d.Added(pos.Subbed(p0)).Len()
+	}
+	for i := 1; i < len(states); i++ {
+		a, b := &states[i-1], &states[i]
+		if f(b.age, b.pos) < 0 {
+			continue
+		}
+		lo, hi := a.age, b.age
+		for hi-lo > 1 {
+			mid := lo + (hi-lo)/2
+			if pos, _ := hermiteState(a, b, mid); f(mid, pos) < 0 {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		pos, v := hermiteState(a, b, hi)
+		return pos.Subbed(p0), v, hi.Seconds()
+	}
+	last := &states[len(states)-1]
+	// P(t) = last.pos - last.vel * (t - last.age)
+	base := last.pos.Subbed(p0).Added(last.vel.ScaledN(last.age.Seconds()))
+	delay = retardedTime(d.Added(base), last.vel)
+	return base.Subbed(last.vel.ScaledN(delay)), last.vel, delay
+}
+
+// ObservedState returns the state of the target as seen by the observer.
+// The light is propagated in the frame of the main anchor.
+// The retarded state is interpolated from the recorded states of the target,
+// which are kept by its GravityHistory config.
+// Beyond the oldest recorded state, the target is assumed to keep the velocity of that state
+func (o *Object) ObservedState(target *Object) (s ObservedState) {
+	d := o.RelPos(target)
+	vo := o.AbsVelocity()
+	disp, vt, t := retardedState(d, target.pastStates())
+	s.Pos = d.Added(disp)
+	s.Vel = vt.Subbed(vo)
+	s.Delay = (time.Duration)(t * (float64)(time.Second))
+	s.Doppler = 1
+
+	l := s.Pos.Len()
+	if l == 0 {
+		return
+	}
+	n := s.Pos.ScaledN(1 / l)
+	bo, bt := vo.ScaledN(1/C), vt.ScaledN(1/C)
+	gammaO, gammaT := lorentzFactor(bo), lorentzFactor(bt)
+	s.Doppler = gammaO * (1 + bo.Dot(n)) / (gammaT * (1 + bt.Dot(n)))
+
+	// relativistic aberration of the observer's motion
+	s.Direction = n
+	if bl := bo.Len(); bl > 0 {
+		bn := bo.ScaledN(1 / bl)
+		dir := n.Added(bn.ScaledN((gammaO - 1) * n.Dot(bn))).Added(bo.ScaledN(gammaO))
+		s.Direction = dir.ScaledN(1 / (gammaO * (1 + n.Dot(bo))))
+	}
+	return
+}
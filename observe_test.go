@@ -0,0 +1,99 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func TestObservedState(t *testing.T) {
+	e := NewEngine(Config{})
+	observer := e.NewObject(ManMadeObj, nil, ZeroVec)
+	target := e.NewObject(ManMadeObj, nil, Vec3{C, 0, 0}, func(o *Object) {
+		o.SetVelocity(Vec3{C / 2, 0, 0})
+	})
+	e.Tick(time.Nanosecond)
+
+	s := observer.ObservedState(target)
+	if d := s.Delay.Seconds() - 2./3; math.Abs(d) > 1e-6 {
+		t.Errorf("Expect light delay 2/3 s, got %v", s.Delay)
+	}
+	if d := s.Pos.X - C*2/3; math.Abs(d) > 1 {
+		t.Errorf("Expect retarded position %v, got %v", C*2/3, s.Pos)
+	}
+	if d := s.Doppler - math.Sqrt(1./3); math.Abs(d) > 1e-6 {
+		t.Errorf("Expect redshift factor %v, got %v", math.Sqrt(1./3), s.Doppler)
+	}
+	if !s.Direction.Equals(UnitX) {
+		t.Errorf("Expect no aberration for still observer, got %v", s.Direction)
+	}
+
+	still := e.NewObject(ManMadeObj, nil, Vec3{C, 0, 0})
+	observer.SetVelocity(Vec3{0, C / 2, 0})
+	e.Tick(time.Nanosecond)
+	s = observer.ObservedState(still)
+	if d := s.Direction; math.Abs(d.Y-0.5) > 1e-6 || math.Abs(d.Len()-1) > 1e-9 {
+		t.Errorf("Expect aberrated direction toward the motion, got %v", d)
+	}
+	if s.Doppler <= 1 {
+		t.Errorf("Expect transverse motion of observer blueshift, got %v", s.Doppler)
+	}
+}
+
+func TestObservedStateHistory(t *testing.T) {
+	e := NewEngine(Config{
+		GravityHistory: GravityHistory{
+			Length:   32,
+			Interval: 100 * time.Millisecond,
+			Mapping:  LinearHistory,
+		},
+	})
+	observer := e.NewObject(ManMadeObj, nil, ZeroVec)
+	target := e.NewObject(ManMadeObj, nil, Vec3{C, 0, 0}, func(o *Object) {
+		o.SetVelocity(Vec3{1000, 0, 0})
+	})
+	const dt = 10 * time.Millisecond
+	for i := 0; i < 300; i++ {
+		e.Tick(dt)
+	}
+	x3 := target.Pos().X
+	// the target stops, but the light from it is still one second late
+	target.SetVelocity(ZeroVec)
+	for i := 0; i < 50; i++ {
+		e.Tick(dt)
+	}
+
+	s := observer.ObservedState(target)
+	// x = x3 - 1000 * (3 - (3.5 - x / C))
+	expect := (x3 + 500) / (1 + 1000/C)
+	if d := s.Pos.X - expect; math.Abs(d) > 1e-3 {
+		t.Errorf("Expect retarded position %v, got %v", expect, s.Pos.X)
+	}
+	if d := s.Vel.X - 1000; math.Abs(d) > 1e-6 {
+		t.Errorf("Expect the velocity when the light left, got %v", s.Vel)
+	}
+	if d := s.Delay.Seconds() - expect/C; math.Abs(d) > 1e-8 {
+		t.Errorf("Expect light delay %v, got %v", expect/C, s.Delay)
+	}
+	if s.Doppler >= 1 {
+		t.Errorf("Expect redshift from the receding target, got %v", s.Doppler)
+	}
+}
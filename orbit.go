@@ -0,0 +1,236 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+)
+
+const (
+	// orbitEpsilon is the threshold of the eccentricity and the inclination
+	// to treat the orbit as circular or equatorial
+	orbitEpsilon = 1e-11
+	// keplerIterations is the maximum iterations of the Newton's method when solving Kepler's equation
+	keplerIterations = 64
+)
+
+// OrbitalElements represents the classical orbital elements.
+// The reference plane is the XY plane of the anchor space, and the reference direction is +X.
+// Parabolic orbits (E == 1) are not supported, since their semi-major axis is infinity
+type OrbitalElements struct {
+	A     float64 // semi-major axis in m, negative for hyperbolic orbits
+	E     float64 // eccentricity
+	I     float64 // inclination in radians, [0, π]
+	LAN   float64 // longitude of the ascending node (Ω) in radians, [0, 2π)
+	ArgPe float64 // argument of periapsis (ω) in radians, [0, 2π)
+	Nu    float64 // true anomaly (ν) in radians, [0, 2π)
+}
+
+// StdGravParam returns the standard gravitational parameter μ = G * M
+func StdGravParam(mass float64) float64 {
+	return G * mass
+}
+
+func normAngle(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	return a
+}
+
+// planeAngle returns the signed angle from u to v about the normal n
+func planeAngle(u, v, n Vec3) float64 {
+	return math.Atan2(n.Dot(u.Cross(v)), u.Dot(v))
+}
+
+// ElementsFromState converts the state vectors relative to the central body into orbital elements.
+// For circular orbits ArgPe is zero and Nu is measured from the ascending node,
+// and for equatorial orbits LAN is zero and the node is the reference direction.
+// The state must have angular momentum, otherwise the orbital plane is undefined and the elements are NaN
+func ElementsFromState(mu float64, r, v Vec3) (el OrbitalElements) {
+	rl := r.Len()
+	h := r.Cross(v)
+	hl := h.Len()
+	hn := h.ScaledN(1 / hl)
+	node := UnitZ.Cross(h)
+	ecc := r.ScaledN(v.SqLen() - mu/rl).Subbed(v.ScaledN(r.Dot(v))).ScaledN(1 / mu)
+
+	el.E = ecc.Len()
+	el.A = -mu / (2 * (v.SqLen()/2 - mu/rl))
+	el.I = math.Acos(math.Max(-1, math.Min(1, h.Z/hl)))
+
+	if node.Len() <= orbitEpsilon*hl {
+		node = UnitX
+	} else {
+		node.Normalize()
+		el.LAN = normAngle(math.Atan2(node.Y, node.X))
+	}
+	if el.E <= orbitEpsilon {
+		el.E = 0
+		el.Nu = normAngle(planeAngle(node, r, hn))
+		return
+	}
+	el.ArgPe = normAngle(planeAngle(node, ecc, hn))
+	el.Nu = normAngle(planeAngle(ecc, r, hn))
+	return
+}
+
+// radialState reports whether the state has no angular momentum,
+// e.g. the body is still or moving along the radius
+func radialState(r, v Vec3) bool {
+	return r.Cross(v).Len() <= orbitEpsilon*r.Len()*v.Len()
+}
+
+// SemiLatusRectum returns the semi-latus rectum p = a * (1 - e^2)
+func (el OrbitalElements) SemiLatusRectum() float64 {
+	return el.A * (1 - el.E*el.E)
+}
+
+// perifocalToFrame rotates the vector in the perifocal frame into the reference frame
+func (el OrbitalElements) perifocalToFrame(v Vec3) Vec3 {
+	so, co := math.Sincos(el.ArgPe)
+	si, ci := math.Sincos(el.I)
+	sO, cO := math.Sincos(el.LAN)
+	// rotate about Z by ω
+	x, y := v.X*co-v.Y*so, v.X*so+v.Y*co
+	// rotate about X by i
+	y, z := y*ci, y*si
+	// rotate about Z by Ω
+	return Vec3{x*cO - y*sO, x*sO + y*cO, z}
+}
+
+// StateVectors converts the orbital elements into the position and the velocity relative to the central body
+func (el OrbitalElements) StateVectors(mu float64) (r, v Vec3) {
+	p := el.SemiLatusRectum()
+	sn, cn := math.Sincos(el.Nu)
+	rl := p / (1 + el.E*cn)
+	vs := math.Sqrt(mu / p)
+	r = el.perifocalToFrame(Vec3{rl * cn, rl * sn, 0})
+	v = el.perifocalToFrame(Vec3{-vs * sn, vs * (el.E + cn), 0})
+	return
+}
+
+// MeanMotion returns the mean motion in radians per second
+func (el OrbitalElements) MeanMotion(mu float64) float64 {
+	a := math.Abs(el.A)
+	return math.Sqrt(mu / (a * a * a))
+}
+
+// Period returns the orbital period in seconds, or +Inf if the orbit is not closed
+func (el OrbitalElements) Period(mu float64) float64 {
+	if el.E >= 1 {
+		return math.Inf(1)
+	}
+	return 2 * math.Pi / el.MeanMotion(mu)
+}
+
+// MeanAnomaly returns the mean anomaly of the true anomaly
+func (el OrbitalElements) MeanAnomaly() float64 {
+	sn, cn := math.Sincos(el.Nu)
+	e := el.E
+	if e < 1 {
+		ea := math.Atan2(math.Sqrt(1-e*e)*sn, e+cn)
+		return normAngle(ea - e*math.Sin(ea))
+	}
+	ha := 2 * math.Atanh(math.Sqrt((e-1)/(e+1))*math.Tan(el.Nu/2))
+	return e*math.Sinh(ha) - ha
+}
+
+// trueAnomalyOf solves Kepler's equation and returns the true anomaly of the mean anomaly
+func (el OrbitalElements) trueAnomalyOf(m float64) float64 {
+	e := el.E
+	if e < 1 {
+		m = normAngle(m)
+		ea := m
+		if e > 0.8 {
+			ea = math.Pi
+		}
+		for i := 0; i < keplerIterations; i++ {
+			d := (ea - e*math.Sin(ea) - m) / (1 - e*math.Cos(ea))
+			ea -= d
+			if math.Abs(d) < 1e-15 {
+				break
+			}
+		}
+		return normAngle(2 * math.Atan2(math.Sqrt(1+e)*math.Sin(ea/2), math.Sqrt(1-e)*math.Cos(ea/2)))
+	}
+	ha := math.Asinh(m / e)
+	for i := 0; i < keplerIterations; i++ {
+		d := (e*math.Sinh(ha) - ha - m) / (e*math.Cosh(ha) - 1)
+		ha -= d
+		if math.Abs(d) < 1e-15 {
+			break
+		}
+	}
+	return normAngle(2 * math.Atan(math.Sqrt((e+1)/(e-1))*math.Tanh(ha/2)))
+}
+
+// Propagate returns the orbital elements after dt seconds on the unperturbed Kepler orbit
+func (el OrbitalElements) Propagate(mu float64, dt float64) OrbitalElements {
+	el.Nu = el.trueAnomalyOf(el.MeanAnomaly() + el.MeanMotion(mu)*dt)
+	return el
+}
+
+// anchorOrbitFrame returns the standard gravitational parameter and the gravity center of the anchor.
+// The anchor will be read locked
+func anchorOrbitFrame(anchor *Object) (mu float64, center Vec3) {
+	anchor.RLock()
+	defer anchor.RUnlock()
+	return StdGravParam(anchor.mass), anchor.gcenter
+}
+
+// orbitState returns the position relative to the gravity center of the anchor, the velocity,
+// and the standard gravitational parameter and the gravity center of the anchor
+func (o *Object) orbitState() (r, v Vec3, mu float64, center Vec3) {
+	o.RLock()
+	anchor, pos, vel := o.anchor, o.pos, o.velocity
+	o.RUnlock()
+	mu, center = anchorOrbitFrame(anchor)
+	return pos.Subbed(center), vel, mu, center
+}
+
+// OrbitMu returns the standard gravitational parameter of the object's anchor
+func (o *Object) OrbitMu() float64 {
+	mu, _ := anchorOrbitFrame(o.Anchor())
+	return mu
+}
+
+// Orbit returns the orbital elements of the object around the gravity center of its anchor.
+// ok is false if the anchor has no mass, e.g. the object is not anchored,
+// or the object has no angular momentum around the anchor, e.g. it's still or moving radially
+func (o *Object) Orbit() (el OrbitalElements, ok bool) {
+	r, v, mu, _ := o.orbitState()
+	if mu <= 0 || radialState(r, v) {
+		return
+	}
+	return ElementsFromState(mu, r, v), true
+}
+
+// SetOrbit sets the position and the velocity of the object by the orbital elements
+// around the gravity center of its anchor. The anchor must have mass
+func (o *Object) SetOrbit(el OrbitalElements) {
+	mu, center := anchorOrbitFrame(o.Anchor())
+	if mu <= 0 {
+		panic("molecular.Object: cannot orbit around a massless anchor")
+	}
+	r, v := el.StateVectors(mu)
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+	o.nextStatus.pos = r.Added(center)
+	o.nextStatus.velocity = v
+}
@@ -0,0 +1,134 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+const earthMu = 3.986004418e14
+
+func TestOrbitalElementsRoundTrip(t *testing.T) {
+	for i := 0; i < 64; i++ {
+		el := OrbitalElements{
+			A:     7e6 + r.Float64()*3e7,
+			E:     r.Float64() * 0.95,
+			I:     r.Float64() * math.Pi,
+			LAN:   r.Float64() * 2 * math.Pi,
+			ArgPe: r.Float64() * 2 * math.Pi,
+			Nu:    r.Float64() * 2 * math.Pi,
+		}
+		if i%2 == 1 {
+			el.A = -el.A
+			el.E += 1.05
+			el.Nu = (r.Float64() - 0.5) * 0.8 * 2 * math.Acos(-1/el.E)
+		}
+		pos, vel := el.StateVectors(earthMu)
+		el2 := ElementsFromState(earthMu, pos, vel)
+		pos2, vel2 := el2.StateVectors(earthMu)
+		if pos.Subbed(pos2).Len() > 1e-3 || vel.Subbed(vel2).Len() > 1e-6 {
+			t.Errorf("State vectors not equal after round trip: %v %v => %+v => %v %v", pos, vel, el2, pos2, vel2)
+		}
+		if math.Abs(el.A-el2.A) > math.Abs(el.A)*1e-9 || math.Abs(el.E-el2.E) > 1e-9 || math.Abs(el.I-el2.I) > 1e-9 {
+			t.Errorf("Elements not equal after round trip: %+v => %+v", el, el2)
+		}
+	}
+}
+
+func TestOrbitalElementsPropagate(t *testing.T) {
+	for _, el := range []OrbitalElements{
+		{A: 1e7, E: 0.3, I: 0.5, LAN: 1, ArgPe: 2, Nu: 0.1},
+		{A: -1e7, E: 1.5, I: 2, LAN: 3, ArgPe: 0.5, Nu: -0.5},
+	} {
+		pos, vel := el.StateVectors(earthMu)
+		// integrate with leapfrog as the reference
+		const step = 0.01
+		const steps = 60000
+		acc := func(p Vec3) Vec3 {
+			l := p.Len()
+			return p.ScaledN(-earthMu / (l * l * l))
+		}
+		p, v := pos, vel
+		for i := 0; i < steps; i++ {
+			v.Add(acc(p).ScaledN(step / 2))
+			p.Add(v.ScaledN(step))
+			v.Add(acc(p).ScaledN(step / 2))
+		}
+		q, w := el.Propagate(earthMu, step*steps).StateVectors(earthMu)
+		if p.Subbed(q).Len() > 10 || v.Subbed(w).Len() > 1e-2 {
+			t.Errorf("Propagated state not match: %v %v, expect %v %v", q, w, p, v)
+		}
+	}
+	el := OrbitalElements{A: 1e7, E: 0.5, I: 0.1, Nu: 1}
+	if p := el.Propagate(earthMu, el.Period(earthMu)); math.Abs(p.Nu-el.Nu) > 1e-9 {
+		t.Errorf("Expect same true anomaly after a period, got %v", p.Nu)
+	}
+}
+
+func TestOrbitMasslessAnchor(t *testing.T) {
+	e := NewEngine(Config{})
+	o := e.NewObject(ManMadeObj, nil, Vec3{100, 0, 0}, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(Vec3{0, 10, 0})
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	if mu := o.OrbitMu(); mu != 0 {
+		t.Errorf("Expect zero mu for an unanchored object, got %v", mu)
+	}
+	if el, ok := o.Orbit(); ok {
+		t.Errorf("Expect no orbit around a massless anchor, got %+v", el)
+	}
+}
+
+func TestOrbitRadialState(t *testing.T) {
+	e := NewEngine(Config{})
+	planet := e.NewObject(NaturalObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 6e24, nil))
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	center := planet.GravityCenter()
+	falling := e.NewObject(ManMadeObj, planet, center.Added(Vec3{X: 1e7}), func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(Vec3{X: -100})
+	})
+	still := e.NewObject(ManMadeObj, planet, center.Added(Vec3{Y: 1e7}), func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+	})
+	e.Tick(time.Millisecond)
+	for _, o := range []*Object{falling, still} {
+		if el, ok := o.Orbit(); ok {
+			t.Errorf("Expect no orbit without angular momentum, got %+v", el)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expect PutOnRails panics without angular momentum")
+				}
+			}()
+			o.PutOnRails()
+		}()
+		if m := o.SimMode(); m != Simulated {
+			t.Errorf("Expect the object still simulated, got %v", m)
+		}
+	}
+}
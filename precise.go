@@ -0,0 +1,153 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"fmt"
+	"math"
+)
+
+// SectorSize is the edge length in m of a sector of PreciseVec3
+const SectorSize = 1 << 24
+
+// PreciseVec3 is a high precision position, which is a sector coordinate and an offset inside the sector.
+// The offset is always in [0, SectorSize), so it keeps a precision about 4nm at any distance
+type PreciseVec3 struct {
+	Sector [3]int64
+	Offset Vec3
+}
+
+// PreciseOf converts the vector to a PreciseVec3
+func PreciseOf(v Vec3) PreciseVec3 {
+	return PreciseVec3{Offset: v}.Normalized()
+}
+
+func normalizeSector(sector *int64, offset *float64) {
+	if *offset >= 0 && *offset < SectorSize {
+		return
+	}
+	s := math.Floor(*offset / SectorSize)
+	*sector += (int64)(s)
+	*offset -= s * SectorSize
+	if *offset >= SectorSize { // rounding error
+		*sector++
+		*offset -= SectorSize
+	}
+}
+
+// Normalized moves the whole sectors inside the offset to the sector coordinate
+func (p PreciseVec3) Normalized() PreciseVec3 {
+	normalizeSector(&p.Sector[0], &p.Offset.X)
+	normalizeSector(&p.Sector[1], &p.Offset.Y)
+	normalizeSector(&p.Sector[2], &p.Offset.Z)
+	return p
+}
+
+// Added returns p + v.
+// v is split into sectors first, so the small offset will not be absorbed by a large v
+func (p PreciseVec3) Added(v Vec3) PreciseVec3 {
+	return p.AddedPrecise(PreciseOf(v))
+}
+
+// AddedPrecise returns p + q
+func (p PreciseVec3) AddedPrecise(q PreciseVec3) PreciseVec3 {
+	for i, s := range q.Sector {
+		p.Sector[i] += s
+	}
+	p.Offset.Add(q.Offset)
+	return p.Normalized()
+}
+
+// Diff returns the displacement p - q.
+// The result is accurate as long as it can be represented by a Vec3
+func (p PreciseVec3) Diff(q PreciseVec3) Vec3 {
+	return Vec3{
+		(float64)(p.Sector[0]-q.Sector[0])*SectorSize + (p.Offset.X - q.Offset.X),
+		(float64)(p.Sector[1]-q.Sector[1])*SectorSize + (p.Offset.Y - q.Offset.Y),
+		(float64)(p.Sector[2]-q.Sector[2])*SectorSize + (p.Offset.Z - q.Offset.Z),
+	}
+}
+
+// Vec3 converts the position to a Vec3, the precision will be lost at large distance
+func (p PreciseVec3) Vec3() Vec3 {
+	return p.Diff(PreciseVec3{})
+}
+
+func (p PreciseVec3) String() string {
+	return fmt.Sprintf("Sector%v+%v", p.Sector, p.Offset)
+}
+
+// PrecisePos returns the position in the main anchor space like AbsPos,
+// but the positions along the anchor chain are summed without losing precision.
+//
+// Only the sum is precise, the position of each object is still a Vec3 relative to its anchor.
+// So an object far from its own anchor only has the float64 precision at that distance,
+// e.g. about 2mm at 1e13m. Anchor the objects to a nearby object to keep them precise
+func (o *Object) PrecisePos() (p PreciseVec3) {
+	o.RLock()
+	p = PreciseOf(o.pos)
+	m := o.anchor
+	o.RUnlock()
+	for m != nil {
+		m.RLock()
+		p = p.Added(m.pos)
+		n := m.anchor
+		m.RUnlock()
+		m = n
+	}
+	return
+}
+
+// PosFrom returns the position of the object relative to the observer's zero position.
+// The positions are summed up to the closest common anchor of the two objects,
+// so it keeps the precision for the nearby objects no matter how far they are from the main anchor.
+// The precision of each anchor link is limited as described in PrecisePos
+func (o *Object) PosFrom(observer *Object) Vec3 {
+	if o == observer {
+		return ZeroVec
+	}
+	// the precise offsets from each anchor of the observer to the observer
+	chain := make(map[*Object]PreciseVec3, 8)
+	var p PreciseVec3
+	chain[observer] = p
+	observer.RLock()
+	m := observer.anchor
+	p = p.Added(observer.pos)
+	observer.RUnlock()
+	for m != nil {
+		chain[m] = p
+		m.RLock()
+		p = p.Added(m.pos)
+		n := m.anchor
+		m.RUnlock()
+		m = n
+	}
+
+	p = PreciseVec3{}
+	for m = o; m != nil; {
+		if q, ok := chain[m]; ok {
+			return p.Diff(q)
+		}
+		m.RLock()
+		p = p.Added(m.pos)
+		n := m.anchor
+		m.RUnlock()
+		m = n
+	}
+	// the objects are not under a same main anchor
+	return observer.RelPos(o)
+}
@@ -0,0 +1,96 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func TestPreciseVec3(t *testing.T) {
+	far := Vec3{X: 1e13, Y: -3e12, Z: 7}
+	p := PreciseOf(far)
+	for i, o := range []float64{p.Offset.X, p.Offset.Y, p.Offset.Z} {
+		if o < 0 || o >= SectorSize {
+			t.Errorf("Expect offset %d in [0, SectorSize), got %v", i, o)
+		}
+	}
+	if v := p.Vec3(); v != far {
+		t.Errorf("Expect %v, got %v", far, v)
+	}
+	q := p.Added(Vec3{X: 1e-3}).Added(Vec3{X: 1e-3})
+	if d := q.Diff(p); math.Abs(d.X-2e-3) > 1e-9 || d.Y != 0 || d.Z != 0 {
+		t.Errorf("Expect diff (0.002, 0, 0), got %v", d)
+	}
+	if d := p.AddedPrecise(PreciseOf(far)).Diff(p); d != far {
+		t.Errorf("Expect %v, got %v", far, d)
+	}
+}
+
+func TestObjectPosFrom(t *testing.T) {
+	e := NewEngine(Config{})
+	star := e.NewObject(NaturalObj, nil, ZeroVec)
+	planet := e.NewObject(NaturalObj, star, Vec3{X: 1e13})
+	a := e.NewObject(ManMadeObj, planet, Vec3{X: 1})
+	b := e.NewObject(ManMadeObj, planet, Vec3{X: 1.001})
+	c := e.NewObject(ManMadeObj, b, Vec3{Y: 0.5})
+
+	if d := b.PosFrom(a); math.Abs(d.X-1e-3) > 1e-9 || d.Y != 0 || d.Z != 0 {
+		t.Errorf("Expect b from a (0.001, 0, 0), got %v", d)
+	}
+	if d := c.PosFrom(a); math.Abs(d.X-1e-3) > 1e-9 || math.Abs(d.Y-0.5) > 1e-9 {
+		t.Errorf("Expect c from a (0.001, 0.5, 0), got %v", d)
+	}
+	if d := a.PosFrom(c); math.Abs(d.X+1e-3) > 1e-9 || math.Abs(d.Y+0.5) > 1e-9 {
+		t.Errorf("Expect a from c (-0.001, -0.5, 0), got %v", d)
+	}
+	if d := star.PosFrom(a); d != (Vec3{X: -1e13 - 1}) {
+		t.Errorf("Expect star from a %v, got %v", Vec3{X: -1e13 - 1}, d)
+	}
+	if d := b.PrecisePos().Diff(a.PrecisePos()); math.Abs(d.X-1e-3) > 1e-9 {
+		t.Errorf("Expect precise positions differ by 0.001, got %v", d)
+	}
+}
+
+func TestObjectPosFromFarLinks(t *testing.T) {
+	e := NewEngine(Config{})
+	star := e.NewObject(NaturalObj, nil, ZeroVec)
+	p1 := e.NewObject(NaturalObj, star, Vec3{X: 1e13, Y: 3e12})
+	p2 := e.NewObject(NaturalObj, star, Vec3{X: 1e13, Y: 3e12, Z: 1024})
+	// the small offsets are kept, since they are under the far anchors
+	a := e.NewObject(ManMadeObj, p1, Vec3{X: 1e-3})
+	b := e.NewObject(ManMadeObj, p2, Vec3{X: 2e-3, Z: -1024})
+	if d := b.PosFrom(a); math.Abs(d.X-1e-3) > 1e-9 || d.Y != 0 || d.Z != 0 {
+		t.Errorf("Expect b from a (0.001, 0, 0), got %v", d)
+	}
+	if d := b.PrecisePos().Diff(a.PrecisePos()); math.Abs(d.X-1e-3) > 1e-9 || d.Y != 0 || d.Z != 0 {
+		t.Errorf("Expect precise positions differ by (0.001, 0, 0), got %v", d)
+	}
+	if d := b.AbsPos().Subbed(a.AbsPos()); d.X == 1e-3 {
+		t.Errorf("Expect AbsPos loses the small offsets, got %v", d)
+	}
+
+	// the objects far from their own anchor only have the float64 precision of that link
+	ulp := math.Nextafter(1e13, math.Inf(1)) - 1e13
+	c := e.NewObject(ManMadeObj, star, Vec3{X: 1e13})
+	f := e.NewObject(ManMadeObj, star, Vec3{X: 1e13 + 1e-3})
+	if d := f.PosFrom(c); d.X == 1e-3 || math.Abs(d.X-1e-3) > ulp {
+		t.Errorf("Expect the offset quantized by the link precision %v, got %v", ulp, d)
+	}
+}
@@ -0,0 +1,297 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Integrator is the numerical integration method used by trajectory prediction
+type Integrator uint8
+
+const (
+	// RK4 is the classical fourth-order Runge-Kutta method
+	RK4 Integrator = iota
+	// Leapfrog is the second-order symplectic velocity Verlet method, it conserves energy well on long orbits
+	Leapfrog
+	// SemiImplicitEuler is the first-order symplectic Euler method
+	SemiImplicitEuler
+)
+
+func (i Integrator) String() string {
+	switch i {
+	case RK4:
+		return "rk4"
+	case Leapfrog:
+		return "leapfrog"
+	case SemiImplicitEuler:
+		return "semi-implicit-euler"
+	default:
+		return fmt.Sprintf("Integrator(%d)", i)
+	}
+}
+
+// TrajectorySample is a sampled state on the predicted trajectory
+type TrajectorySample struct {
+	Time     time.Duration // the time since now
+	Pos, Vel Vec3          // the position and the velocity relative to the anchor
+}
+
+// SOITransition represents the predicted object moves from a sphere of influence to another
+type SOITransition struct {
+	Time     time.Duration
+	From, To *Object
+	Pos      Vec3 // the position relative to the anchor
+}
+
+// PredictedTrajectory is the result of Engine.PredictTrajectory
+type PredictedTrajectory struct {
+	Samples     []TrajectorySample
+	Transitions []SOITransition
+	// Impact is the body that the object will hit, or nil if no collision is predicted.
+	// The prediction stops at the impact
+	Impact     *Object
+	ImpactTime time.Duration
+}
+
+// predictBody is a snapshot of a gravity source
+type predictBody struct {
+	obj    *Object
+	mu     float64
+	radius float64
+	soi    float64
+	pos    Vec3 // the gravity center relative to the anchor
+	vel    Vec3
+	orbit  *OrbitalElements // nil means the body moves linearly
+}
+
+// posAt returns the predicted gravity center of the body at the time
+func (b *predictBody) posAt(t, mu float64, center Vec3) Vec3 {
+	if b.orbit != nil {
+		p, _ := b.orbit.Propagate(mu, t).StateVectors(mu)
+		return p.Added(center)
+	}
+	return b.pos.Added(b.vel.ScaledN(t))
+}
+
+// soiRadius returns the radius of the sphere of influence r = a * (m / M) ^ (2/5)
+func soiRadius(dist, mass, primary float64) float64 {
+	if primary <= 0 {
+		return math.Inf(1)
+	}
+	return dist * math.Pow(mass/primary, 0.4)
+}
+
+// predictor simulates a point mass under the snapshot gravity sources
+type predictor struct {
+	anchor   predictBody
+	siblings []predictBody
+	// the position of each sibling at the current substep
+	sibPos    []Vec3
+	anchorSOI float64
+	outer     *Object
+}
+
+func (p *predictor) updateSiblings(t float64) {
+	for i := range p.siblings {
+		p.sibPos[i] = p.siblings[i].posAt(t, p.anchor.mu, p.anchor.pos)
+	}
+}
+
+func fieldAcc(center, pos Vec3, mu, radius float64) Vec3 {
+	acc := center.Subbed(pos)
+	lSq := acc.SqLen()
+	if lSq == 0 {
+		return ZeroVec
+	}
+	if lSq < radius*radius {
+		return acc.ScaledN(mu / (radius * radius * radius))
+	}
+	return acc.ScaledN(mu / (lSq * math.Sqrt(lSq)))
+}
+
+// accAt returns the gravity acceleration at the position, updateSiblings must be called first
+func (p *predictor) accAt(pos Vec3) Vec3 {
+	acc := fieldAcc(p.anchor.pos, pos, p.anchor.mu, p.anchor.radius)
+	for i, s := range p.siblings {
+		acc.Add(fieldAcc(p.sibPos[i], pos, s.mu, s.radius))
+	}
+	return acc
+}
+
+func (p *predictor) step(integrator Integrator, t, h float64, pos, vel Vec3) (Vec3, Vec3) {
+	switch integrator {
+	case SemiImplicitEuler:
+		p.updateSiblings(t)
+		vel = vel.Added(p.accAt(pos).ScaledN(h))
+		pos = pos.Added(vel.ScaledN(h))
+	case Leapfrog:
+		p.updateSiblings(t)
+		vel = vel.Added(p.accAt(pos).ScaledN(h / 2))
+		pos = pos.Added(vel.ScaledN(h))
+		p.updateSiblings(t + h)
+		vel = vel.Added(p.accAt(pos).ScaledN(h / 2))
+	default:
+		p.updateSiblings(t)
+		a1 := p.accAt(pos)
+		p.updateSiblings(t + h/2)
+		p2 := pos.Added(vel.ScaledN(h / 2))
+		v2 := vel.Added(a1.ScaledN(h / 2))
+		a2 := p.accAt(p2)
+		p3 := pos.Added(v2.ScaledN(h / 2))
+		v3 := vel.Added(a2.ScaledN(h / 2))
+		a3 := p.accAt(p3)
+		p.updateSiblings(t + h)
+		p4 := pos.Added(v3.ScaledN(h))
+		v4 := vel.Added(a3.ScaledN(h))
+		a4 := p.accAt(p4)
+		pos = pos.Added(vel.Added(v2.ScaledN(2)).Added(v3.ScaledN(2)).Added(v4).ScaledN(h / 6))
+		vel = vel.Added(a1.Added(a2.ScaledN(2)).Added(a3.ScaledN(2)).Added(a4).ScaledN(h / 6))
+	}
+	return pos, vel
+}
+
+// dominant returns the body whose sphere of influence contains the position,
+// and the body that the position is inside its radius
+func (p *predictor) dominant(pos Vec3) (soi, hit *Object) {
+	best := math.Inf(1)
+	for i, s := range p.siblings {
+		d := p.sibPos[i].Subbed(pos).Len()
+		if d < s.radius {
+			hit = s.obj
+		}
+		if d < s.soi && s.soi < best {
+			best = s.soi
+			soi = s.obj
+		}
+	}
+	d := p.anchor.pos.Subbed(pos).Len()
+	if d < p.anchor.radius {
+		hit = p.anchor.obj
+	}
+	if soi == nil {
+		if d <= p.anchorSOI {
+			soi = p.anchor.obj
+		} else {
+			soi = p.outer
+		}
+	}
+	return
+}
+
+// snapshotBody reads the gravity source of the object.
+// pos is the object's zero position relative to the anchor of the predicted object
+func snapshotBody(o *Object, pos, vel Vec3) predictBody {
+	o.RLock()
+	defer o.RUnlock()
+	b := predictBody{
+		obj: o,
+		mu:  StdGravParam(o.mass),
+		pos: pos.Added(o.gcenter),
+		vel: vel,
+	}
+	if o.gfield != nil {
+		b.radius = o.gfield.Radius()
+	}
+	return b
+}
+
+// PredictTrajectory simulates a lightweight copy of the object forward under the current gravity sources,
+// which are the anchor and the siblings of the object. The siblings move on their Kepler orbits around the anchor.
+// The object is treated as a point mass at its zero position, and the engine state will not be changed.
+// The trajectory is sampled at every step, with the integrator from Config.PredictIntegrator
+func (e *Engine) PredictTrajectory(o *Object, duration, step time.Duration) (path *PredictedTrajectory) {
+	if step <= 0 {
+		panic("molecular.Engine: prediction step must be positive")
+	}
+	o.RLock()
+	anchor := o.anchor
+	pos, vel := o.pos, o.velocity
+	var siblings []*Object
+	o.forEachSibling(func(s *Object) {
+		siblings = append(siblings, s)
+	})
+	o.RUnlock()
+
+	p := &predictor{
+		anchorSOI: math.Inf(1),
+	}
+	if anchor != nil {
+		p.anchor = snapshotBody(anchor, ZeroVec, ZeroVec)
+		anchor.RLock()
+		if outer := anchor.anchor; outer != nil {
+			p.outer = outer
+			outer.RLock()
+			p.anchorSOI = soiRadius(anchor.pos.Subbed(outer.gcenter).Len(), anchor.mass, outer.mass)
+			outer.RUnlock()
+		}
+		anchor.RUnlock()
+	}
+	for _, s := range siblings {
+		s.RLock()
+		spos, svel := s.pos, s.velocity
+		s.RUnlock()
+		b := snapshotBody(s, spos, svel)
+		if b.mu <= 0 {
+			continue
+		}
+		rel := b.pos.Subbed(p.anchor.pos)
+		if p.anchor.mu > 0 {
+			if el := ElementsFromState(p.anchor.mu, rel, svel); el.E < 1 && !math.IsNaN(el.A) && !radialState(rel, svel) {
+				b.orbit = &el
+			}
+			b.soi = soiRadius(rel.Len(), b.mu, p.anchor.mu)
+		} else {
+			b.soi = math.Inf(1)
+		}
+		p.siblings = append(p.siblings, b)
+	}
+	p.sibPos = make([]Vec3, len(p.siblings))
+
+	n := (int)(duration / step)
+	path = &PredictedTrajectory{
+		Samples: make([]TrajectorySample, 0, n+1),
+	}
+	path.Samples = append(path.Samples, TrajectorySample{Pos: pos, Vel: vel})
+	p.updateSiblings(0)
+	current, _ := p.dominant(pos)
+	h := step.Seconds()
+	for i := 1; i <= n; i++ {
+		pos, vel = p.step(e.cfg.PredictIntegrator, (float64)(i-1)*h, h, pos, vel)
+		t := step * (time.Duration)(i)
+		p.updateSiblings((float64)(i) * h)
+		path.Samples = append(path.Samples, TrajectorySample{Time: t, Pos: pos, Vel: vel})
+		soi, hit := p.dominant(pos)
+		if soi != current {
+			path.Transitions = append(path.Transitions, SOITransition{
+				Time: t,
+				From: current,
+				To:   soi,
+				Pos:  pos,
+			})
+			current = soi
+		}
+		if hit != nil {
+			path.Impact = hit
+			path.ImpactTime = t
+			break
+		}
+	}
+	return
+}
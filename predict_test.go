@@ -0,0 +1,100 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func newTestPlanet(e *Engine, anchor *Object, pos Vec3, mass, radius float64) *Object {
+	o := e.NewObject(NaturalObj, anchor, pos, func(o *Object) {
+		o.AddBlock(newTestBlock(Vec3{-0.5, -0.5, -0.5}, mass, nil))
+	})
+	o.SetRadius(radius)
+	return o
+}
+
+func TestPredictTrajectory(t *testing.T) {
+	e := NewEngine(Config{})
+	planet := newTestPlanet(e, nil, ZeroVec, 6e24, 6.4e6)
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	el := OrbitalElements{A: 1e7, E: 0.1}
+	mu := planet.Mass() * G
+	pos, vel := el.StateVectors(mu)
+	sat := e.NewObject(ManMadeObj, planet, pos, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(vel)
+	})
+	e.Tick(time.Millisecond)
+	start, startVel := sat.Pos(), sat.Velocity()
+
+	period := time.Duration(el.Period(mu) * float64(time.Second))
+	path := e.PredictTrajectory(sat, period, time.Second)
+	if path.Impact != nil {
+		t.Fatalf("Expect no impact, got %v", path.Impact)
+	}
+	last := path.Samples[len(path.Samples)-1]
+	expect, _ := ElementsFromState(mu, start, startVel).Propagate(mu, last.Time.Seconds()).StateVectors(mu)
+	if d := last.Pos.Subbed(expect).Len(); d > 1 {
+		t.Errorf("Expect predicted position %v, got %v", expect, last.Pos)
+	}
+	if p := sat.Pos(); p != start {
+		t.Errorf("Expect prediction not mutate the object")
+	}
+
+	sat.SetVelocity(ZeroVec)
+	e.Tick(time.Millisecond)
+	path = e.PredictTrajectory(sat, time.Hour, time.Second)
+	if path.Impact != planet || path.ImpactTime <= 0 || path.ImpactTime >= time.Hour {
+		t.Errorf("Expect falling object hits the planet, got %v at %v", path.Impact, path.ImpactTime)
+	}
+}
+
+func TestPredictTrajectorySOI(t *testing.T) {
+	e := NewEngine(Config{})
+	planet := newTestPlanet(e, nil, ZeroVec, 6e24, 6.4e6)
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	mu := planet.Mass() * G
+	moonPos := Vec3{3.8e8, 0, 0}
+	moonVel := Vec3{0, math.Sqrt(mu / moonPos.X), 0}
+	moon := newTestPlanet(e, planet, moonPos, 7e22, 1.7e6)
+	moon.SetVelocity(moonVel)
+	sat := e.NewObject(ManMadeObj, planet, moonPos.Added(Vec3{1e8, 1e7, 0}), func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(moonVel.Added(Vec3{-1e5, 0, 0}))
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	path := e.PredictTrajectory(sat, time.Second*3000, time.Second)
+	if len(path.Transitions) != 2 {
+		t.Fatalf("Expect enter and exit the moon's SOI, got %v", path.Transitions)
+	}
+	if tr := path.Transitions[0]; tr.From != planet || tr.To != moon {
+		t.Errorf("Expect enter the moon's SOI first, got %v", tr)
+	}
+	if tr := path.Transitions[1]; tr.From != moon || tr.To != planet {
+		t.Errorf("Expect exit the moon's SOI, got %v", tr)
+	}
+}
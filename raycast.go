@@ -0,0 +1,375 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"math"
+)
+
+const (
+	// sweepMaxIterations is the maximum bisection steps to find the time of impact of a shape
+	sweepMaxIterations = 64
+	sweepTolerance     = 1e-10
+)
+
+// RayHit describes where a ray or a sweeping shape hits a block
+type RayHit struct {
+	Object *Object
+	Block  Block
+	// Face is the face of the block that was hit.
+	// For a ShapedBlock or a sphere sweep, it's the face which normal is the closest to the hit normal
+	Face Facing
+	// Distance is the distance that the ray travelled before hit
+	Distance float64
+	// Pos is the absolute position of the hit point.
+	// For sweeps, it's the center of the shape when hit
+	Pos Vec3
+	// Normal is the unit normal of the hit surface in absolute space
+	Normal Vec3
+}
+
+// Raycast returns the first block that the ray hits within maxDist.
+// The origin is the absolute position, and the filter can be nil.
+// Only the objects that the filter returns true will be tested
+func (e *Engine) Raycast(origin, dir Vec3, maxDist float64, filter func(*Object) bool) (hit RayHit, ok bool) {
+	return e.sweep(&sweepQuery{origin: origin, dir: dir}, maxDist, filter)
+}
+
+// SweepSphere returns the first block that the sphere hits when moving along dir within maxDist
+func (e *Engine) SweepSphere(center Vec3, radius float64, dir Vec3, maxDist float64, filter func(*Object) bool) (hit RayHit, ok bool) {
+	radius = math.Abs(radius)
+	return e.sweep(&sweepQuery{
+		origin: center,
+		half:   Vec3{radius, radius, radius},
+		radius: radius,
+		dir:    dir,
+	}, maxDist, filter)
+}
+
+// SweepBox returns the first block that the axis-aligned box hits when moving along dir within maxDist.
+// The box is in absolute space. When tested against a rotated object,
+// the bounding box of the rotated box will be used
+func (e *Engine) SweepBox(box *Cube, dir Vec3, maxDist float64, filter func(*Object) bool) (hit RayHit, ok bool) {
+	return e.sweep(&sweepQuery{origin: box.Center(), half: box.S.ScaledN(0.5), dir: dir}, maxDist, filter)
+}
+
+// sweepQuery describes the shape that is swept.
+// It's a sphere if radius is positive, otherwise a box with the half extents
+type sweepQuery struct {
+	origin, half Vec3
+	radius       float64
+	dir          Vec3
+	// skipStart ignores the blocks that the shape is already overlapping or touching at the origin
+	skipStart bool
+	// accept reports whether a closer hit can be taken, it can be nil.
+	// It's called with the hit object read locked
+	accept func(hit *RayHit) bool
+}
+
+func (e *Engine) sweep(q *sweepQuery, maxDist float64, filter func(*Object) bool) (hit RayHit, ok bool) {
+	if q.dir.IsZero() || maxDist < 0 {
+		return
+	}
+	q.dir.Normalize()
+	hit.Distance = maxDist
+
+	e.RLock()
+	defer e.RUnlock()
+
+	for _, o := range e.objects {
+		if filter != nil && !filter(o) {
+			continue
+		}
+		if o.raycastLocked(q, &hit) {
+			ok = true
+		}
+	}
+	if ok {
+		hit.Pos = q.origin.Added(q.dir.ScaledN(hit.Distance))
+	}
+	return
+}
+
+// raycastLocked tests the blocks of the object and updates the hit if there is a closer one.
+// The blocks are visited through the block index in the order of the cells that the ray passes
+func (o *Object) raycastLocked(q *sweepQuery, hit *RayHit) (ok bool) {
+	abs := o.AbsPos()
+
+	o.RLock()
+	defer o.RUnlock()
+
+	if len(o.blocks) == 0 {
+		return
+	}
+	origin, dir, half, radius := q.origin, q.dir, q.half, q.radius
+	// test the bounding sphere first
+	center := abs.Added(o.gcenter).Added(o.bounds.Center().Subbed(o.gcenter).RotatedXYZ(o.angle))
+	bradius := o.bounds.S.Len()/2 + half.Len()
+	w := center.Subbed(origin)
+	t := w.Dot(dir)
+	if w.SqLen()-t*t > bradius*bradius || t+bradius < 0 || t-bradius > hit.Distance {
+		return
+	}
+
+	// transform the ray into the object's local space
+	lorigin := origin.Subbed(abs).Subbed(o.gcenter).UnrotatedXYZ(o.angle).Added(o.gcenter)
+	ldir := dir.UnrotatedXYZ(o.angle)
+	expand := Vec3{radius, radius, radius}
+	if radius <= 0 {
+		expand = UnitX.UnrotatedXYZ(o.angle).Abs().ScaledN(half.X).
+			Added(UnitY.UnrotatedXYZ(o.angle).Abs().ScaledN(half.Y)).
+			Added(UnitZ.UnrotatedXYZ(o.angle).Abs().ScaledN(half.Z))
+	}
+	// the shape that is swept in local space
+	var moving Shape
+	if radius > 0 {
+		moving = &Sphere{C: lorigin, R: radius}
+	} else {
+		moving = &Cube{P: lorigin.Subbed(expand), S: expand.ScaledN(2)}
+	}
+
+	t0, t1, _, _, hitted := raySlab(lorigin, ldir, &o.bounds, expand)
+	if !hitted || t1 < 0 || t0 > hit.Distance {
+		return
+	}
+	seen := make(set[Block])
+	test := func(b Block) bool {
+		if seen.Has(b) {
+			return true
+		}
+		seen.Put(b)
+		var (
+			dist   float64
+			normal Vec3
+			face   Facing
+		)
+		if _, shaped := b.(ShapedBlock); !shaped && radius <= 0 {
+			var (
+				tmax float64
+				axis int
+				neg  bool
+			)
+			dist, tmax, axis, neg, hitted = raySlab(lorigin, ldir, b.Outline(), expand)
+			if !hitted || tmax < 0 || dist > hit.Distance {
+				return true
+			}
+			if dist <= 0 {
+				if q.skipStart {
+					return true
+				}
+				dist = 0
+			}
+			switch axis {
+			case 0:
+				normal.X = 1
+			case 1:
+				normal.Y = 1
+			case 2:
+				normal.Z = 1
+			}
+			f := 0
+			if neg {
+				normal.Negate()
+				f = 1
+			}
+			face = AxisFacings[axis][f]
+		} else {
+			// test the bounding box before the exact shape
+			if _, _, _, hitted = rayCube(lorigin, ldir, b.Outline(), expand); !hitted {
+				return true
+			}
+			if dist, normal, hitted = sweepShape(moving, ldir, BlockShape(b), hit.Distance, q.skipStart); !hitted {
+				return true
+			}
+			face = FacingOf(normal)
+		}
+		h := RayHit{
+			Object:   o,
+			Block:    b,
+			Face:     face,
+			Distance: dist,
+			Normal:   normal.RotatedXYZ(o.angle),
+		}
+		if q.accept != nil && !q.accept(&h) {
+			return true
+		}
+		ok = true
+		*hit = h
+		return true
+	}
+	o.index.walkRay(lorigin, ldir, math.Max(t0, 0), math.Min(t1, hit.Distance), func(ta, tb float64) bool {
+		if ta > hit.Distance {
+			return false
+		}
+		// the blocks that the part of the ray inside the cell may touch
+		box := segmentBounds(lorigin.Added(ldir.ScaledN(ta)), lorigin.Added(ldir.ScaledN(tb)))
+		box.P.Sub(expand)
+		box.S.Add(expand.ScaledN(2))
+		o.index.QueryBox(box, test)
+		return true
+	})
+	return
+}
+
+// raySlab returns the distances that the ray enters and exits the expanded cube,
+// and the axis of the entry face. neg is true if the entry face is facing the negative direction
+func raySlab(origin, dir Vec3, c *Cube, expand Vec3) (tmin, tmax float64, axis int, neg bool, ok bool) {
+	lo, hi := c.P.Subbed(expand), c.EndPos().Added(expand)
+	var (
+		o = [3]float64{origin.X, origin.Y, origin.Z}
+		d = [3]float64{dir.X, dir.Y, dir.Z}
+		l = [3]float64{lo.X, lo.Y, lo.Z}
+		h = [3]float64{hi.X, hi.Y, hi.Z}
+	)
+	tmin, tmax = math.Inf(-1), math.Inf(1)
+	for i := 0; i < 3; i++ {
+		if d[i] == 0 {
+			if o[i] < l[i] || o[i] > h[i] {
+				return
+			}
+			continue
+		}
+		t1, t2 := (l[i]-o[i])/d[i], (h[i]-o[i])/d[i]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		if t1 > tmin {
+			tmin, axis, neg = t1, i, d[i] > 0
+		}
+		if t2 < tmax {
+			tmax = t2
+		}
+		if tmin > tmax {
+			return
+		}
+	}
+	ok = true
+	return
+}
+
+// rayCube returns the distance that the ray enters the expanded cube,
+// and the axis of the entry face. neg is true if the entry face is facing the negative direction.
+// If the origin is inside the cube, the distance will be zero
+func rayCube(origin, dir Vec3, c *Cube, expand Vec3) (dist float64, axis int, neg bool, ok bool) {
+	tmin, tmax, axis, neg, ok := raySlab(origin, dir, c, expand)
+	if !ok || tmax < 0 {
+		return 0, 0, false, false
+	}
+	if tmin < 0 {
+		tmin = 0
+	}
+	return tmin, axis, neg, true
+}
+
+// walkRay invokes the callback with the distances that the ray enters and exits each cell
+// between t0 and t1, in the order that the ray passes them, using a 3D DDA.
+// The walk stops if the callback returns false
+func (x *blockIndex) walkRay(origin, dir Vec3, t0, t1 float64, cb func(ta, tb float64) bool) {
+	if t0 > t1 {
+		return
+	}
+	var (
+		p     = origin.Added(dir.ScaledN(t0))
+		o     = [3]float64{origin.X, origin.Y, origin.Z}
+		d     = [3]float64{dir.X, dir.Y, dir.Z}
+		cell  = [3]float64{math.Floor(p.X / x.cellSize), math.Floor(p.Y / x.cellSize), math.Floor(p.Z / x.cellSize)}
+		next  [3]float64 // the distance to the next cell boundary on each axis
+		delta [3]float64 // the distance between two cell boundaries on each axis
+	)
+	for i := 0; i < 3; i++ {
+		switch {
+		case d[i] > 0:
+			next[i] = ((cell[i]+1)*x.cellSize - o[i]) / d[i]
+			delta[i] = x.cellSize / d[i]
+		case d[i] < 0:
+			next[i] = (cell[i]*x.cellSize - o[i]) / d[i]
+			delta[i] = -x.cellSize / d[i]
+		default:
+			next[i] = math.Inf(1)
+		}
+	}
+	for ta := t0; ; {
+		i := 0
+		if next[1] < next[i] {
+			i = 1
+		}
+		if next[2] < next[i] {
+			i = 2
+		}
+		tb := math.Min(next[i], t1)
+		if !cb(ta, tb) || tb >= t1 {
+			return
+		}
+		ta = tb
+		next[i] += delta[i]
+	}
+}
+
+// sweptShape is the shape that moved along the segment between from and to
+type sweptShape struct {
+	shape    Shape
+	from, to Vec3
+}
+
+func (s *sweptShape) Support(dir Vec3) Vec3 {
+	p := s.shape.Support(dir)
+	if dir.Dot(s.to) > dir.Dot(s.from) {
+		return p.Added(s.to)
+	}
+	return p.Added(s.from)
+}
+
+func (s *sweptShape) Bounds() *Cube {
+	b := s.shape.Bounds()
+	c := *b
+	b.P.Add(s.from)
+	c.P.Add(s.to)
+	return b.Extend(&c)
+}
+
+// sweepShape returns the distance that the moving shape travels along the unit direction
+// before it touches the target, and the normal of the target's surface at the contact.
+// The time of impact is found by bisecting the swept shape with GJK.
+// If skipStart is true, the target is ignored when the shape is already touching it
+func sweepShape(moving Shape, dir Vec3, target Shape, maxDist float64, skipStart bool) (dist float64, normal Vec3, ok bool) {
+	if !Intersect(&sweptShape{shape: moving, to: dir.ScaledN(maxDist)}, target) {
+		return
+	}
+	lo, hi := 0.0, maxDist
+	if Intersect(moving, target) {
+		if skipStart {
+			return
+		}
+		hi = 0
+	}
+	for i := 0; i < sweepMaxIterations && hi-lo > sweepTolerance; i++ {
+		mid := (lo + hi) / 2
+		if Intersect(&sweptShape{shape: moving, to: dir.ScaledN(mid)}, target) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	// EPA needs a little penetration to find the contact normal
+	d := dir.ScaledN(hi + sweepTolerance)
+	if c, ok := Penetration(&sweptShape{shape: moving, from: d, to: d}, target); ok {
+		normal = c.Normal.Negated()
+	} else {
+		normal = dir.Negated()
+	}
+	return hi, normal, true
+}
@@ -0,0 +1,157 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func vecNear(a, b Vec3) bool {
+	return a.Subbed(b).SqLen() < 1e-18
+}
+
+func TestRaycast(t *testing.T) {
+	e := NewEngine(Config{})
+	b1 := newTestBlock(ZeroVec, 1, nil)
+	b2 := newTestBlock(ZeroVec, 1, nil)
+	o1 := e.NewObject(ManMadeObj, nil, Vec3{5, 0, 0}, func(o *Object) {
+		o.AddBlock(b1)
+	})
+	e.NewObject(ManMadeObj, nil, Vec3{10, 0, 0}, func(o *Object) {
+		o.AddBlock(b2)
+		o.SetAngle(Vec3{0, 0, math.Pi / 2})
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	origin := Vec3{0, 0.5, 0.5}
+	hit, ok := e.Raycast(origin, UnitX, 100, nil)
+	if !ok {
+		t.Fatalf("Expect ray hit")
+	}
+	if hit.Block != b1 || hit.Face != LEFT || math.Abs(hit.Distance-5) > 1e-9 || !vecNear(hit.Normal, UnitX.Negated()) {
+		t.Errorf("Unexpected hit %#v", hit)
+	}
+	if _, ok := e.Raycast(origin, UnitX, 4, nil); ok {
+		t.Errorf("Expect ray not hit within distance 4")
+	}
+	if _, ok := e.Raycast(origin, UnitY, 100, nil); ok {
+		t.Errorf("Expect ray not hit on y-axis")
+	}
+
+	hit, ok = e.Raycast(origin, UnitX, 100, func(o *Object) bool { return o != o1 })
+	if !ok {
+		t.Fatalf("Expect ray hit the rotated object")
+	}
+	if hit.Block != b2 || hit.Face != TOP || math.Abs(hit.Distance-10) > 1e-9 || !vecNear(hit.Normal, UnitX.Negated()) {
+		t.Errorf("Unexpected hit on rotated object %#v", hit)
+	}
+
+	hit, ok = e.SweepSphere(origin, 0.5, UnitX, 100, nil)
+	if !ok || hit.Block != b1 || math.Abs(hit.Distance-4.5) > 1e-9 {
+		t.Errorf("Unexpected sphere sweep hit %#v", hit)
+	}
+	hit, ok = e.SweepBox(NewCube(Vec3{0, 0.8, 0}, OneVec), UnitX, 100, nil)
+	if !ok || hit.Block != b1 || math.Abs(hit.Distance-4) > 1e-9 {
+		t.Errorf("Unexpected box sweep hit %#v", hit)
+	}
+}
+
+func TestSweepSphereCorner(t *testing.T) {
+	e := NewEngine(Config{})
+	b := newTestBlock(ZeroVec, 1, nil)
+	e.NewObject(ManMadeObj, nil, Vec3{5, 0, 0}, func(o *Object) {
+		o.AddBlock(b)
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	// the sphere passes the edge of the block, and should hit it at x = 5 - 0.3
+	hit, ok := e.SweepSphere(Vec3{0, 1.4, 0.5}, 0.5, UnitX, 100, nil)
+	if !ok || hit.Block != b || math.Abs(hit.Distance-4.7) > 1e-6 {
+		t.Fatalf("Unexpected sphere sweep hit on the edge %#v", hit)
+	}
+	if hit.Normal.Subbed(Vec3{-0.6, 0.8, 0}).Len() > 1e-3 {
+		t.Errorf("Expect edge normal (-0.6, 0.8, 0), got %v", hit.Normal)
+	}
+	// the box sweep with same extents hits the corner, but the sphere does not
+	if _, ok := e.SweepBox(NewCube(Vec3{-0.5, 0.9, 0.9}, OneVec), UnitX, 100, nil); !ok {
+		t.Errorf("Expect box sweep hit near the corner")
+	}
+	if hit, ok := e.SweepSphere(Vec3{0, 1.4, 1.4}, 0.5, UnitX, 100, nil); ok {
+		t.Errorf("Expect sphere sweep to pass the corner, got %#v", hit)
+	}
+}
+
+func TestRaycastShapedBlock(t *testing.T) {
+	e := NewEngine(Config{})
+	ball := newBallBlock(ZeroVec, 1)
+	e.NewObject(ManMadeObj, nil, Vec3{5, 0, 0}, func(o *Object) {
+		o.AddBlock(ball)
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	hit, ok := e.Raycast(Vec3{0, 0.5, 0.5}, UnitX, 100, nil)
+	if !ok || hit.Block != ball || math.Abs(hit.Distance-5) > 1e-6 || hit.Face != LEFT {
+		t.Fatalf("Unexpected hit on the ball %#v", hit)
+	}
+	// the ray passes the corner of the outline but misses the ball
+	if hit, ok := e.Raycast(Vec3{0, 0.95, 0.95}, UnitX, 100, nil); ok {
+		t.Errorf("Expect ray to miss the ball, got %#v", hit)
+	}
+	// 0.5 - 0.5*cos(30°) = 0.0670
+	hit, ok = e.Raycast(Vec3{0, 0.75, 0.5}, UnitX, 100, nil)
+	if !ok || math.Abs(hit.Distance-(5.5-math.Sqrt(0.1875))) > 1e-6 {
+		t.Errorf("Unexpected hit distance on the ball %#v", hit)
+	}
+}
+
+func TestRaycastLongObject(t *testing.T) {
+	e := NewEngine(Config{})
+	var blocks []*testBlock
+	e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		for i := 0; i < 100; i++ {
+			b := newTestBlock(Vec3{(float64)(i), (float64)(i % 7), 0}, 1, nil)
+			blocks = append(blocks, b)
+			o.AddBlock(b)
+		}
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	for _, i := range []int{0, 13, 50, 99} {
+		origin := Vec3{(float64)(i) + 0.5, (float64)(i%7) + 0.5, -10}
+		hit, ok := e.Raycast(origin, UnitZ, 100, nil)
+		if !ok || hit.Block != blocks[i] || math.Abs(hit.Distance-10) > 1e-9 || hit.Face != BACK {
+			t.Errorf("Unexpected hit for block %d: %#v", i, hit)
+		}
+	}
+	// along the row, the first block in the way is hit
+	hit, ok := e.Raycast(Vec3{-5, 3.5, 0.5}, UnitX, 200, nil)
+	if !ok || hit.Block != blocks[3] || math.Abs(hit.Distance-8) > 1e-9 {
+		t.Errorf("Unexpected hit along the row %#v", hit)
+	}
+	hit, ok = e.Raycast(Vec3{105, 3.5, 0.5}, UnitX.Negated(), 200, nil)
+	if !ok || hit.Block != blocks[94] || math.Abs(hit.Distance-10) > 1e-9 {
+		t.Errorf("Unexpected hit along the row backwards %#v", hit)
+	}
+}
@@ -0,0 +1,230 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"fmt"
+	"math"
+)
+
+// Shape is a convex collision shape
+type Shape interface {
+	// Support returns the farthest point of the shape along the direction
+	Support(dir Vec3) Vec3
+	// Bounds returns the axis-aligned bounding box of the shape
+	Bounds() *Cube
+}
+
+// ShapedBlock is a Block that has a collision shape other than its Outline
+type ShapedBlock interface {
+	Block
+	// Shape returns the collision shape in the object's local space.
+	// The shape should be inside the block's Outline
+	Shape() Shape
+}
+
+// BlockShape returns the collision shape of the block.
+// If the block is not a ShapedBlock, its Outline will be returned
+func BlockShape(b Block) Shape {
+	if sb, ok := b.(ShapedBlock); ok {
+		if s := sb.Shape(); s != nil {
+			return s
+		}
+	}
+	return b.Outline()
+}
+
+var (
+	_ Shape = (*Cube)(nil)
+	_ Shape = (*OBB)(nil)
+	_ Shape = (*Sphere)(nil)
+	_ Shape = (*Capsule)(nil)
+	_ Shape = (*Cylinder)(nil)
+	_ Shape = (*ConvexHull)(nil)
+)
+
+type Sphere struct {
+	C Vec3    // Center
+	R float64 // Radius
+}
+
+func NewSphere(center Vec3, radius float64) *Sphere {
+	return &Sphere{
+		C: center,
+		R: math.Abs(radius),
+	}
+}
+
+func (s *Sphere) String() string {
+	return fmt.Sprintf("Sphere(center=%v, radius=%v)", s.C, s.R)
+}
+
+func (s *Sphere) Support(dir Vec3) Vec3 {
+	return s.C.Added(dir.Normalized().ScaledN(s.R))
+}
+
+func (s *Sphere) Bounds() *Cube {
+	return &Cube{
+		P: s.C.Subbed(Vec3{s.R, s.R, s.R}),
+		S: Vec3{s.R * 2, s.R * 2, s.R * 2},
+	}
+}
+
+// Capsule is a sphere swept along a segment
+type Capsule struct {
+	A, B Vec3    // The end points of the segment
+	R    float64 // Radius
+}
+
+func NewCapsule(a, b Vec3, radius float64) *Capsule {
+	return &Capsule{
+		A: a,
+		B: b,
+		R: math.Abs(radius),
+	}
+}
+
+func (c *Capsule) String() string {
+	return fmt.Sprintf("Capsule(a=%v, b=%v, radius=%v)", c.A, c.B, c.R)
+}
+
+func (c *Capsule) Support(dir Vec3) Vec3 {
+	p := c.A
+	if dir.Dot(c.B.Subbed(c.A)) > 0 {
+		p = c.B
+	}
+	return p.Added(dir.Normalized().ScaledN(c.R))
+}
+
+func (c *Capsule) Bounds() *Cube {
+	b := segmentBounds(c.A, c.B)
+	r := Vec3{c.R, c.R, c.R}
+	b.P.Sub(r)
+	b.S.Add(r.ScaledN(2))
+	return b
+}
+
+// Cylinder is a circle with radius R swept along the segment from A to B
+type Cylinder struct {
+	A, B Vec3    // The centers of the two caps
+	R    float64 // Radius
+}
+
+func NewCylinder(a, b Vec3, radius float64) *Cylinder {
+	return &Cylinder{
+		A: a,
+		B: b,
+		R: math.Abs(radius),
+	}
+}
+
+func (c *Cylinder) String() string {
+	return fmt.Sprintf("Cylinder(a=%v, b=%v, radius=%v)", c.A, c.B, c.R)
+}
+
+func (c *Cylinder) Support(dir Vec3) Vec3 {
+	axis := c.B.Subbed(c.A).Normalized()
+	d := dir.Dot(axis)
+	p := c.A
+	if d > 0 {
+		p = c.B
+	}
+	if radial := dir.Subbed(axis.ScaledN(d)); radial.SqLen() > 0 {
+		p.Add(radial.Normalized().ScaledN(c.R))
+	}
+	return p
+}
+
+func (c *Cylinder) Bounds() *Cube {
+	b := segmentBounds(c.A, c.B)
+	axis := c.B.Subbed(c.A).Normalized()
+	// the extent of a circle on each axis
+	r := Vec3{
+		c.R * math.Sqrt(math.Max(0, 1-axis.X*axis.X)),
+		c.R * math.Sqrt(math.Max(0, 1-axis.Y*axis.Y)),
+		c.R * math.Sqrt(math.Max(0, 1-axis.Z*axis.Z)),
+	}
+	b.P.Sub(r)
+	b.S.Add(r.ScaledN(2))
+	return b
+}
+
+// ConvexHull is the convex hull of a set of points
+type ConvexHull struct {
+	Points []Vec3
+}
+
+// NewConvexHull creates a ConvexHull with the points.
+// The points do not have to be on the hull, the inner points will never be picked by Support
+func NewConvexHull(points ...Vec3) *ConvexHull {
+	if len(points) == 0 {
+		panic("molecular.ConvexHull: points cannot be empty")
+	}
+	return &ConvexHull{
+		Points: points,
+	}
+}
+
+func (h *ConvexHull) Support(dir Vec3) (p Vec3) {
+	best := math.Inf(-1)
+	for _, q := range h.Points {
+		if d := q.Dot(dir); d > best {
+			best, p = d, q
+		}
+	}
+	return
+}
+
+func (h *ConvexHull) Bounds() *Cube {
+	b := &Cube{P: h.Points[0]}
+	for _, p := range h.Points[1:] {
+		b.Extend(&Cube{P: p})
+	}
+	return b
+}
+
+// transformedShape is a shape that rotated by angle around the pivot, and then moved by the offset
+type transformedShape struct {
+	shape                Shape
+	offset, pivot, angle Vec3
+}
+
+// TransformShape returns the shape which is rotated by the angles around the pivot,
+// and then moved by the offset
+func TransformShape(s Shape, offset, pivot, angle Vec3) Shape {
+	return &transformedShape{
+		shape:  s,
+		offset: offset,
+		pivot:  pivot,
+		angle:  angle,
+	}
+}
+
+func (t *transformedShape) Support(dir Vec3) Vec3 {
+	p := t.shape.Support(dir.UnrotatedXYZ(t.angle))
+	p.Sub(t.pivot).RotateXYZ(t.angle).Add(t.pivot).Add(t.offset)
+	return p
+}
+
+func (t *transformedShape) Bounds() *Cube {
+	return NewOBBFromCube(t.shape.Bounds(), t.offset, t.pivot, t.angle).Bounds()
+}
+
+func segmentBounds(a, b Vec3) *Cube {
+	c := &Cube{P: a}
+	return c.Extend(&Cube{P: b})
+}
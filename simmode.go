@@ -0,0 +1,230 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SimMode is the simulation mode of an object
+type SimMode uint32
+
+const (
+	// Simulated objects are integrated every tick
+	Simulated SimMode = iota
+	// OnRails objects follow their Trajectory, forces will not affect them
+	OnRails
+	// Asleep objects do not move until they are woken up.
+	// Their blocks are still ticked, so block changes, firing thrusters
+	// and the gravity that is no longer negligible will wake them up
+	Asleep
+	// Quarantined objects panicked during a tick or had an invalid state,
+	// they are not ticked or synced until Simulate is called
+	Quarantined
+)
+
+func (m SimMode) String() string {
+	switch m {
+	case Simulated:
+		return "simulated"
+	case OnRails:
+		return "on-rails"
+	case Asleep:
+		return "asleep"
+	case Quarantined:
+		return "quarantined"
+	default:
+		return fmt.Sprintf("SimMode(%d)", m)
+	}
+}
+
+// Trajectory returns the position and the velocity relative to the anchor
+// at the elapsed time in seconds since the trajectory is set
+type Trajectory func(t float64) (pos, vel Vec3)
+
+// KeplerTrajectory returns the Trajectory that follows the unperturbed Kepler orbit.
+// center is the position of the central body relative to the anchor
+func KeplerTrajectory(mu float64, el OrbitalElements, center Vec3) Trajectory {
+	return func(t float64) (pos, vel Vec3) {
+		pos, vel = el.Propagate(mu, t).StateVectors(mu)
+		pos.Add(center)
+		return
+	}
+}
+
+// SimMode returns the simulation mode of the object
+func (o *Object) SimMode() SimMode {
+	return (SimMode)(o.simMode.Load())
+}
+
+// Sleeping reports whether the object is asleep
+func (o *Object) Sleeping() bool {
+	return o.SimMode() == Asleep
+}
+
+// Sleep puts the object asleep, and stops its motion
+func (o *Object) Sleep() {
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+	o.nextStatus.velocity = ZeroVec
+	o.nextStatus.headVel = ZeroVec
+	o.sleepPending = true
+}
+
+// Wake wakes the object up if it's asleep
+func (o *Object) Wake() {
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+	o.wakeLocked()
+}
+
+func (o *Object) wakeLocked() {
+	o.sleepPending = false
+	o.sleepTicks = 0
+	o.simMode.CompareAndSwap((uint32)(Asleep), (uint32)(Simulated))
+}
+
+// Simulate puts the object back to the fully simulated mode
+func (o *Object) Simulate() {
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+	o.rails = nil
+	o.wakeLocked()
+	o.simMode.Store((uint32)(Simulated))
+}
+
+// SetRails puts the object on the rails, the trajectory starts from the next tick
+func (o *Object) SetRails(traj Trajectory) {
+	if traj == nil {
+		panic("molecular.Object: trajectory cannot be nil")
+	}
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+	o.rails = traj
+	o.railsTime = 0
+	o.sleepPending = false
+	o.simMode.Store((uint32)(OnRails))
+}
+
+// PutOnRails puts the object on the rails of its current Kepler orbit around the anchor.
+// The anchor must have mass, and the object must have angular momentum around it
+func (o *Object) PutOnRails() {
+	r, v, mu, center := o.orbitState()
+	if mu <= 0 {
+		panic("molecular.Object: cannot orbit around a massless anchor")
+	}
+	if radialState(r, v) {
+		panic("molecular.Object: cannot orbit without angular momentum")
+	}
+	o.SetRails(KeplerTrajectory(mu, ElementsFromState(mu, r, v), center))
+}
+
+// tickRails moves the object along its trajectory
+func (o *Object) tickRails(dt time.Duration) {
+	o.RLock()
+	defer o.RUnlock()
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+
+	if o.rails == nil {
+		return
+	}
+	apt := o.anchor.ProperTime(dt)
+	o.railsTime += apt
+	o.nextStatus.pos, o.nextStatus.velocity = o.rails(o.railsTime)
+	av := o.headVel
+	av.Add(o.nextStatus.headVel)
+	av.ScaleN(apt / 2)
+	o.nextStatus.angle.Add(av).ModN(math.Pi)
+}
+
+// sleepGravityTicks is the period in ticks that a sleeping object checks the gravity on it,
+// since the gravity is summed from all the siblings
+const sleepGravityTicks = 8
+
+// tickAsleep ticks the blocks of the sleeping object without moving it.
+// The object will be woken up if the blocks changed its mass or gravity center,
+// applied any force or torque, any thruster is firing,
+// or the gravity on it is no longer negligible, which is checked every sleepGravityTicks
+func (o *Object) tickAsleep(dt time.Duration) {
+	o.RLock()
+	defer o.RUnlock()
+	o.nextMux.Lock()
+	defer o.nextMux.Unlock()
+
+	o.tickForce = ZeroVec
+	o.tickTorque = ZeroVec
+	gcenter, mass := o.tickBlocksLocked(dt.Seconds() * o.reLorentzFactor())
+	if mass != o.mass || gcenter != o.gcenter ||
+		!o.tickForce.IsZero() || !o.tickTorque.IsZero() || o.firingLocked() {
+		o.wakeLocked()
+		return
+	}
+	if o.sleepTicks++; o.sleepTicks < sleepGravityTicks {
+		return
+	}
+	o.sleepTicks = 0
+	if o.anchor != nil && o.gravityAtLocked(o.pos).SqLen() > o.e.minAccelSq {
+		o.wakeLocked()
+	}
+}
+
+// tickSleepLocked counts the ticks that the object is almost still,
+// and puts the object asleep after Config.SleepTicks
+func (o *Object) tickSleepLocked(pt float64) {
+	n := o.e.cfg.SleepTicks
+	if n <= 0 {
+		return
+	}
+	e := o.e
+	next := &o.nextStatus
+	acc := next.velocity.Subbed(o.velocity).ScaledN(1 / pt)
+	if next.velocity.SqLen() > e.minSpeedSq || next.headVel.SqLen() > e.minSpeedSq || acc.SqLen() > e.minAccelSq {
+		o.sleepTicks = 0
+		return
+	}
+	if o.sleepTicks++; o.sleepTicks >= n {
+		next.velocity = ZeroVec
+		next.headVel = ZeroVec
+		o.sleepPending = true
+	}
+}
+
+// syncSleepLocked updates the sleeping state during sync.
+// A sleeping object will be woken up if its next status is changed by others
+func (o *Object) syncSleepLocked() {
+	if o.sleepPending {
+		o.sleepPending = false
+		o.sleepTicks = 0
+		// the object may be pushed after it decided to sleep
+		if o.SimMode() == Simulated && o.nextStatus.velocity.IsZero() && o.nextStatus.headVel.IsZero() {
+			o.simMode.Store((uint32)(Asleep))
+		}
+		return
+	}
+	if o.SimMode() != Asleep {
+		return
+	}
+	next := &o.nextStatus
+	if len(o.nextCalls) > 0 || next.anchor != o.anchor ||
+		next.pos != o.pos || next.velocity != o.velocity ||
+		next.angle != o.angle || next.headVel != o.headVel {
+		o.wakeLocked()
+	}
+}
@@ -0,0 +1,196 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func TestObjectSleep(t *testing.T) {
+	e := NewEngine(Config{
+		SleepTicks: 3,
+	})
+	b := newTestBlock(ZeroVec, 1, nil)
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(b)
+	})
+	for i := 0; i < 5; i++ {
+		e.Tick(time.Millisecond)
+	}
+	if !o.Sleeping() {
+		t.Fatalf("Expect still object falls asleep")
+	}
+	o.ApplyImpulse(b, Vec3{1, 0, 0})
+	e.Tick(time.Millisecond)
+	if o.Sleeping() {
+		t.Fatalf("Expect object woken up by impulse")
+	}
+	e.Tick(time.Millisecond)
+	if p := o.Pos(); p.X <= 0 {
+		t.Errorf("Expect woken object moves, got %v", p)
+	}
+}
+
+func TestObjectSleepOptIn(t *testing.T) {
+	e := NewEngine(Config{})
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+	})
+	for i := 0; i < 100; i++ {
+		e.Tick(time.Millisecond)
+	}
+	if o.Sleeping() {
+		t.Fatalf("Expect objects never fall asleep by default")
+	}
+	o.Sleep()
+	e.Tick(time.Millisecond)
+	if !o.Sleeping() {
+		t.Errorf("Expect object sleeps after Sleep is called")
+	}
+}
+
+func TestObjectWakeByThruster(t *testing.T) {
+	e := NewEngine(Config{
+		SleepTicks: 3,
+	})
+	tank := &testTank{testBlock: *newTestBlock(UnitY, 1, nil), propellant: 1}
+	engine := &testThruster{testBlock: *newTestBlock(ZeroVec, 1, nil), thrust: 100, isp: 300}
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(tank, engine)
+	})
+	for i := 0; i < 5; i++ {
+		e.Tick(time.Millisecond)
+	}
+	if !o.Sleeping() {
+		t.Fatalf("Expect still object falls asleep")
+	}
+	// the block changes its own state, no object API is called
+	engine.throttle = 1
+	e.Tick(time.Millisecond)
+	if o.Sleeping() {
+		t.Fatalf("Expect object woken up by the firing thruster")
+	}
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	if v := o.Velocity(); v.Y <= 0 {
+		t.Errorf("Expect woken object accelerates, got %v", v)
+	}
+}
+
+type forceBlock struct {
+	*testBlock
+	force Vec3
+}
+
+func (b *forceBlock) Tick(dt float64) {
+	b.obj.TickForce().Add(b.force)
+}
+
+func TestObjectWakeByBlockForce(t *testing.T) {
+	e := NewEngine(Config{
+		SleepTicks: 3,
+	})
+	b := &forceBlock{testBlock: newTestBlock(ZeroVec, 1, nil)}
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(b)
+	})
+	for i := 0; i < 5; i++ {
+		e.Tick(time.Millisecond)
+	}
+	if !o.Sleeping() {
+		t.Fatalf("Expect still object falls asleep")
+	}
+	// the block pushes the object without any thruster
+	b.force = Vec3{X: 1}
+	e.Tick(time.Millisecond)
+	if o.Sleeping() {
+		t.Fatalf("Expect object woken up by the block force")
+	}
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	if v := o.Velocity(); v.X <= 0 {
+		t.Errorf("Expect woken object accelerates, got %v", v)
+	}
+}
+
+func TestObjectWakeByGravity(t *testing.T) {
+	e := NewEngine(Config{
+		MinSpeed:   1e-6,
+		SleepTicks: 3,
+	})
+	root := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+	})
+	o := e.NewObject(ManMadeObj, root, Vec3{100, 0, 0}, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+	})
+	for i := 0; i < 5; i++ {
+		e.Tick(time.Millisecond)
+	}
+	if !o.Sleeping() {
+		t.Fatalf("Expect still object falls asleep")
+	}
+	// a planet appears next to the sleeping object
+	newTestPlanet(e, root, Vec3{1e7, 0, 0}, 6e24, 6.4e6)
+	// the sleeping object checks the gravity every few ticks
+	for i := 0; i < 10; i++ {
+		e.Tick(time.Millisecond)
+	}
+	if o.Sleeping() {
+		t.Fatalf("Expect object woken up by the gravity of the planet")
+	}
+	e.Tick(time.Millisecond)
+	if v := o.Velocity(); v.X <= 0 {
+		t.Errorf("Expect woken object falls toward the planet, got %v", v)
+	}
+}
+
+func TestObjectOnRails(t *testing.T) {
+	e := NewEngine(Config{})
+	planet := e.NewObject(NaturalObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 6e24, nil))
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	mu := planet.Mass() * G
+	el := OrbitalElements{A: 1e7, E: 0.2, I: 0.3, LAN: 1, ArgPe: 2, Nu: 0.5}
+	pos, vel := el.StateVectors(mu)
+	sat := e.NewObject(ManMadeObj, planet, pos.Added(Vec3{0.5, 0.5, 0.5}), func(o *Object) {
+		o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+		o.SetVelocity(vel)
+	})
+	e.Tick(time.Millisecond)
+	sat.SetOrbit(el)
+	e.Tick(time.Millisecond)
+	el, _ = sat.Orbit()
+	sat.PutOnRails()
+	if m := sat.SimMode(); m != OnRails {
+		t.Fatalf("Expect object on rails, got %v", m)
+	}
+	for i := 0; i < 100; i++ {
+		e.Tick(time.Second)
+	}
+	got, _ := sat.Orbit()
+	expect := el.Propagate(mu, 100)
+	if math.Abs(got.Nu-expect.Nu) > 1e-9 || math.Abs(got.A-expect.A) > 1e-3 || math.Abs(got.E-expect.E) > 1e-9 {
+		t.Errorf("Expect orbit %+v, got %+v", expect, got)
+	}
+}
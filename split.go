@@ -0,0 +1,172 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"slices"
+)
+
+// blockIslands groups the blocks that are connected by shared faces.
+// The index must contain exactly the same blocks, or it can be nil
+func blockIslands(blocks []Block, index *blockIndex) (islands [][]Block) {
+	if len(blocks) == 0 {
+		return
+	}
+	if index == nil {
+		index = newBlockIndex(defaultBlockCellSize)
+		for _, b := range blocks {
+			index.Add(b)
+		}
+	}
+	visited := make(set[Block], len(blocks))
+	queue := make([]Block, 0, len(blocks))
+	for _, b := range blocks {
+		if visited.Has(b) {
+			continue
+		}
+		visited.Put(b)
+		queue = append(queue[:0], b)
+		island := make([]Block, 0, 1)
+		for len(queue) > 0 {
+			c := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			island = append(island, c)
+			for f := TOP; f <= BACK; f++ {
+				index.QueryBox(facingSlab(c.Outline(), f), func(b Block) bool {
+					if !visited.Has(b) && c.Outline().SharesFace(b.Outline()) {
+						visited.Put(b)
+						queue = append(queue, b)
+					}
+					return true
+				})
+			}
+		}
+		islands = append(islands, island)
+	}
+	return
+}
+
+// Islands returns the groups of the object's blocks that are connected by shared faces
+func (o *Object) Islands() [][]Block {
+	o.RLock()
+	defer o.RUnlock()
+	return blockIslands(o.blocks, o.index)
+}
+
+// Split separates the disconnected islands of the object.
+// The heaviest island stays in the object, and each of the other islands
+// will become a new object under the same anchor.
+// Split returns the new objects, or nil if the object is still connected
+func (o *Object) Split() (parts []*Object) {
+	o.Lock()
+	o.nextMux.Lock()
+	var index *blockIndex
+	if len(o.nextCalls) == 0 {
+		// nothing pending, so the index matches the next blocks
+		index = o.index
+	}
+	islands := blockIslands(o.nextStatus.blocks, index)
+	if len(islands) <= 1 {
+		o.nextMux.Unlock()
+		o.Unlock()
+		return
+	}
+	keep, keepMass := 0, -1.0
+	for i, island := range islands {
+		if _, m := blocksCenterAndMass(island); m > keepMass || m == keepMass && len(island) > len(islands[keep]) {
+			keep, keepMass = i, m
+		}
+	}
+	for i, island := range islands {
+		if i != keep {
+			o.detachBlocksLocked(island)
+		}
+	}
+	o.needSplit.Store(false)
+	o.nextMux.Unlock()
+	o.Unlock()
+
+	parts = make([]*Object, 0, len(islands)-1)
+	for i, island := range islands {
+		if i != keep {
+			parts = append(parts, o.spawnFragment(island))
+		}
+	}
+	return
+}
+
+// SplitBlocks moves the blocks out of the object into a new object under the same anchor,
+// and returns the new object. SplitBlocks should not be called inside a tick
+func (o *Object) SplitBlocks(blocks []Block) *Object {
+	o.Lock()
+	o.nextMux.Lock()
+	o.detachBlocksLocked(blocks)
+	o.nextMux.Unlock()
+	o.Unlock()
+	return o.spawnFragment(blocks)
+}
+
+// detachBlocksLocked removes the blocks and their states from both the current and the next status,
+// so the mass and the gravity center are correct before the next tick.
+// The object's lock and nextMux must be held
+func (o *Object) detachBlocksLocked(blocks []Block) {
+	detached := make(set[Block], len(blocks))
+	for _, b := range blocks {
+		o.removeBlock(b)
+		delete(o.damages, b)
+		delete(o.blockTemps, b)
+		o.index.Remove(b)
+		detached.Put(b)
+	}
+	o.objStatus.blocks = slices.DeleteFunc(o.objStatus.blocks, detached.Has)
+
+	gcenter, mass := blocksCenterAndMass(o.objStatus.blocks)
+	if o.mass > 0 && mass > 0 && gcenter != o.gcenter {
+		// keep the remaining blocks stay still when the rotate pivot moved
+		offset := pivotOffset(o.gcenter, o.angle).Subbed(pivotOffset(gcenter, o.angle))
+		dv := o.headVel.Cross(gcenter.Subbed(o.gcenter).RotatedXYZ(o.angle))
+		o.objStatus.pos.Add(offset)
+		o.nextStatus.pos.Add(offset)
+		o.objStatus.velocity.Add(dv)
+		o.nextStatus.velocity.Add(dv)
+	}
+	bounds := blocksBounds(o.objStatus.blocks)
+	o.objStatus.gcenter, o.nextStatus.gcenter = gcenter, gcenter
+	o.objStatus.mass, o.nextStatus.mass = mass, mass
+	o.objStatus.bounds, o.nextStatus.bounds = bounds, bounds
+}
+
+// splitObjects splits the objects that removed blocks during the last tick
+func (e *Engine) splitObjects() {
+	e.RLock()
+	objs := e.objsCache[:0]
+	for _, o := range e.objects {
+		if o.needSplit.Load() {
+			objs = append(objs, o)
+		}
+	}
+	e.RUnlock()
+
+	for _, o := range objs {
+		o.needSplit.Store(false)
+		if parts := o.Split(); parts != nil && e.cfg.OnObjectSplit != nil {
+			e.cfg.OnObjectSplit(o, parts)
+		}
+	}
+	clear(objs)
+	e.objsCache = objs[:0]
+}
@@ -0,0 +1,133 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+const (
+	// G0 is the standard gravity in m/s^2, which is used to convert the specific impulse to the exhaust velocity
+	G0 = 9.80665
+)
+
+// ThrusterBlock is a block that produces thrust by burning propellant from the TankBlocks of the same object
+type ThrusterBlock interface {
+	Block
+	// Nozzle returns the face that the exhaust goes out, the thrust pushes toward the opposite face
+	Nozzle() Facing
+	// Thrust returns the thrust in N at full throttle
+	Thrust() float64
+	// Isp returns the specific impulse in s
+	Isp() float64
+	// Throttle returns the current throttle in range [0, 1]
+	Throttle() float64
+}
+
+// TankBlock is a block that stores propellant
+type TankBlock interface {
+	Block
+	// Propellant returns the mass of the remaining propellant in kg, it should be included in Block.Mass
+	Propellant() float64
+	// Drain removes the propellant, and returns the mass that actually drained
+	Drain(mass float64) float64
+}
+
+// TickTorque returns the torque vector that can be edit during a tick.
+// You should never read/write the vector concurrently or outside a tick.
+func (o *Object) TickTorque() *Vec3 {
+	return &o.tickTorque
+}
+
+// ApplyForceAtLocked applies the force at the point during the tick.
+// The point is in the object's local space, and the force is in the anchor space.
+// It should only be called inside a tick, e.g. in Block.Tick
+func (o *Object) ApplyForceAtLocked(pos Vec3, force Vec3) {
+	o.tickForce.Add(force)
+	r := pos.Subbed(o.nextStatus.gcenter).RotatedXYZ(o.angle)
+	o.tickTorque.Add(r.Cross(force))
+}
+
+// tickThrustersLocked burns the propellant and applies the thrust of each thruster.
+// The propellant consumed is based on the proper time, so the rocket equation
+// holds in the object's own frame
+func (o *Object) tickThrustersLocked(pt float64) {
+	var tanks []TankBlock
+	for _, b := range o.blocks {
+		if t, ok := b.(TankBlock); ok {
+			tanks = append(tanks, t)
+		}
+	}
+	for _, b := range o.blocks {
+		t, ok := b.(ThrusterBlock)
+		if !ok {
+			continue
+		}
+		thrust := t.Thrust() * t.Throttle()
+		isp := t.Isp()
+		if thrust <= 0 || isp <= 0 {
+			continue
+		}
+		// ṁ = F / (Isp * g0)
+		need := thrust / (isp * G0) * pt
+		drained := 0.0
+		for _, tank := range tanks {
+			if drained >= need {
+				break
+			}
+			if tank.Propellant() > 0 {
+				drained += tank.Drain(need - drained)
+			}
+		}
+		if drained <= 0 {
+			continue
+		}
+		if drained < need {
+			thrust *= drained / need
+		}
+		dir := t.Nozzle().Opposite().Normal().RotatedXYZ(o.angle)
+		o.ApplyForceAtLocked(t.Outline().Center(), dir.ScaledN(thrust))
+	}
+}
+
+// firingLocked reports whether any thruster is throttled up and there is propellant to burn
+func (o *Object) firingLocked() bool {
+	firing, fueled := false, false
+	for _, b := range o.blocks {
+		if t, ok := b.(ThrusterBlock); ok && t.Thrust()*t.Throttle() > 0 && t.Isp() > 0 {
+			firing = true
+		}
+		if t, ok := b.(TankBlock); ok && t.Propellant() > 0 {
+			fueled = true
+		}
+	}
+	return firing && fueled
+}
+
+// inertiaAccel returns the angular acceleration caused by the torque in anchor space.
+// The inertia is the principal moments of inertia in the local space
+func inertiaAccel(inertia Vec3, angle Vec3, torque Vec3) Vec3 {
+	t := torque.UnrotatedXYZ(angle)
+	return Vec3{
+		safeDiv(t.X, inertia.X),
+		safeDiv(t.Y, inertia.Y),
+		safeDiv(t.Z, inertia.Z),
+	}.RotatedXYZ(angle)
+}
+
+func safeDiv(a, b float64) float64 {
+	if b <= 0 {
+		return 0
+	}
+	return a / b
+}
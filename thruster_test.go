@@ -0,0 +1,89 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+type testTank struct {
+	testBlock
+	propellant float64
+}
+
+func (b *testTank) Mass() float64       { return b.mass + b.propellant }
+func (b *testTank) Propellant() float64 { return b.propellant }
+func (b *testTank) Drain(mass float64) float64 {
+	mass = math.Min(mass, b.propellant)
+	b.propellant -= mass
+	return mass
+}
+
+type testThruster struct {
+	testBlock
+	thrust, isp, throttle float64
+}
+
+func (b *testThruster) Nozzle() Facing    { return BOTTOM }
+func (b *testThruster) Thrust() float64   { return b.thrust }
+func (b *testThruster) Isp() float64      { return b.isp }
+func (b *testThruster) Throttle() float64 { return b.throttle }
+
+func TestThrusterRocketEquation(t *testing.T) {
+	e := NewEngine(Config{})
+	tank := &testTank{testBlock: *newTestBlock(UnitY, 1, nil), propellant: 3}
+	engine := &testThruster{testBlock: *newTestBlock(ZeroVec, 1, nil), thrust: 1000, isp: 300, throttle: 1}
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(tank, engine)
+	})
+	for i := 0; i < 2000 && tank.propellant > 0; i++ {
+		e.Tick(time.Millisecond * 10)
+	}
+	e.Tick(time.Millisecond)
+	if tank.propellant > 0 {
+		t.Fatalf("Expect propellant exhausted, got %v", tank.propellant)
+	}
+	expect := 300 * G0 * math.Log(5.0/2.0)
+	if v := o.Velocity(); math.Abs(v.Y-expect) > expect*0.01 || math.Abs(v.X) > 1e-9 || math.Abs(v.Z) > 1e-9 {
+		t.Errorf("Expect delta-v (0, %v, 0), got %v", expect, v)
+	}
+	if w := o.HeadingVel(); !w.IsZero() {
+		t.Errorf("Expect no torque from centered thrust, got %v", w)
+	}
+}
+
+func TestThrusterTorque(t *testing.T) {
+	e := NewEngine(Config{})
+	tank := &testTank{testBlock: *newTestBlock(ZeroVec, 1, nil), propellant: 1}
+	engine := &testThruster{testBlock: *newTestBlock(UnitX, 1, nil), thrust: 10, isp: 300, throttle: 0.5}
+	o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+		o.AddBlock(tank, engine)
+	})
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	if w := o.HeadingVel(); w.Z <= 0 {
+		t.Errorf("Expect positive angular velocity about Z, got %v", w)
+	}
+	if tank.propellant >= 1 {
+		t.Errorf("Expect propellant drained")
+	}
+}
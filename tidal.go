@@ -0,0 +1,167 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+const (
+	defaultTidalStrength  = 1e3
+	defaultRocheFragments = 8
+)
+
+// tidalAccLocked samples the gravity field at each block's center, and returns the average acceleration.
+// The torque caused by the gravity gradient is added to the tick torque,
+// and the tidal force on each block beyond Config.TidalStrength damages the block.
+// The maximum tidal force on a block will be saved as the tidal stress
+func (o *Object) tidalAccLocked(gcenter Vec3, mass float64, pt float64) (acc Vec3) {
+	center := o.pos.Added(gcenter)
+	fields := growToLen(o.tidalCache, len(o.blocks))
+	for i, b := range o.blocks {
+		r := b.Outline().Center().Subbed(gcenter).RotatedXYZ(o.angle)
+		g := o.gravityAtLocked(center.Added(r))
+		fields[i] = g
+		acc.Add(g.ScaledN(b.Mass() / mass))
+	}
+
+	var (
+		torque Vec3
+		stress float64
+	)
+	strength := o.e.cfg.TidalStrength
+	for i, b := range o.blocks {
+		m := b.Mass()
+		r := b.Outline().Center().Subbed(gcenter).RotatedXYZ(o.angle)
+		torque.Add(r.Cross(fields[i].ScaledN(m)))
+		s := fields[i].Subbed(acc).Len() * m
+		if s > stress {
+			stress = s
+		}
+		if s > strength {
+			_, brittleness := blockDurability(b)
+			o.damageBlockLocked(b, (s-strength)*pt/o.e.cfg.DamageImpulse*(1+brittleness))
+		}
+	}
+	clear(fields)
+	o.tidalCache = fields[:0]
+	o.tidalStress = stress
+	o.tickTorque.Add(torque)
+	return
+}
+
+// TidalStress returns the maximum tidal force in N acting on a single block during the last tick.
+// It's always zero if Config.TidalForces is false
+func (o *Object) TidalStress() float64 {
+	o.nextMux.RLock()
+	defer o.nextMux.RUnlock()
+	return o.tidalStress
+}
+
+// RocheLimit returns the distance from the primary body's gravity center
+// that the object will be torn apart by the tidal forces.
+// It uses the rigid body formula d = r * (2 * M / m) ^ (1/3),
+// where r is the radius of the object's gravity field
+func (o *Object) RocheLimit(primary *Object) float64 {
+	r := o.GravityField().Radius()
+	m := o.GravityField().Mass()
+	if r <= 0 || m <= 0 {
+		return 0
+	}
+	return r * math.Cbrt(2*primary.GravityField().Mass()/m)
+}
+
+// rocheSplit breaks the object into Config.RocheFragments slices along the direction to the primary body.
+// The nearest slice stays in the object, and the others become new objects.
+// The object and the fragments are marked as broken, so they will not be broken again
+func (o *Object) rocheSplit(primary *Object) (parts []*Object) {
+	pc := primary.GravityField().Pos()
+
+	o.RLock()
+	dir := pc.Subbed(o.pos.Added(o.gcenter)).UnrotatedXYZ(o.angle)
+	blocks := append(([]Block)(nil), o.blocks...)
+	gcenter := o.gcenter
+	mass := o.mass
+	o.RUnlock()
+
+	n := min(o.e.cfg.RocheFragments, len(blocks))
+	if n <= 1 {
+		return nil
+	}
+	depth := func(b Block) float64 {
+		return -b.Outline().Center().Subbed(gcenter).Dot(dir)
+	}
+	slices.SortFunc(blocks, func(a, b Block) int {
+		return cmp.Compare(depth(a), depth(b))
+	})
+
+	radius := o.GravityField().Radius()
+	o.rocheBroken.Store(true)
+	rest := mass
+	parts = make([]*Object, 0, n-1)
+	for i := n - 1; i > 0; i-- {
+		group := blocks[i*len(blocks)/n : (i+1)*len(blocks)/n]
+		part := o.SplitBlocks(group)
+		part.rocheBroken.Store(true)
+		if mass > 0 {
+			_, fmass := blocksCenterAndMass(group)
+			rest -= fmass
+			part.SetRadius(radius * math.Cbrt(fmass/mass))
+		}
+		parts = append(parts, part)
+	}
+	if mass > 0 {
+		o.SetRadius(radius * math.Cbrt(max(rest, 0)/mass))
+	}
+	return
+}
+
+// RocheBroken reports whether the object is a fragment of a Roche breakup
+func (o *Object) RocheBroken() bool {
+	return o.rocheBroken.Load()
+}
+
+// rocheBreakup splits the natural objects that are inside the Roche limit of their anchors
+func (e *Engine) rocheBreakup() {
+	if !e.cfg.RocheBreakup {
+		return
+	}
+
+	e.RLock()
+	objs := e.objsCache[:0]
+	for _, o := range e.objects {
+		if o.typ == NaturalObj && o.anchor != e.mainAnchor && !o.rocheBroken.Load() {
+			objs = append(objs, o)
+		}
+	}
+	e.RUnlock()
+
+	for _, o := range objs {
+		a := o.Anchor()
+		d := o.Pos().Added(o.GravityField().Pos()).Subbed(a.GravityField().Pos())
+		if limit := o.RocheLimit(a); limit <= 0 || d.SqLen() >= limit*limit {
+			continue
+		}
+		if parts := o.rocheSplit(a); parts != nil && e.cfg.OnObjectSplit != nil {
+			e.cfg.OnObjectSplit(o, parts)
+		}
+	}
+	clear(objs)
+	e.objsCache = objs[:0]
+}
@@ -0,0 +1,138 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+// newTestRig creates an asymmetric object with blocks centered at (±5, 0, 0) and (0, 0, ±3)
+func newTestRig(e *Engine, typ ObjType, anchor *Object, pos Vec3, angle Vec3) (*Object, []Block) {
+	var blocks []Block
+	for _, c := range []Vec3{{X: 5}, {X: -5}, {Z: 3}, {Z: -3}} {
+		blocks = append(blocks, newTestBlock(c.Subbed(Vec3{X: 0.5, Y: 0.5, Z: 0.5}), 1, nil))
+	}
+	o := e.NewObject(typ, anchor, pos, func(o *Object) {
+		o.AddBlock(blocks...)
+		o.SetAngle(angle)
+	})
+	return o, blocks
+}
+
+func TestTidalTorque(t *testing.T) {
+	e := NewEngine(Config{
+		TidalForces: true,
+	})
+	planet := newTestPlanet(e, nil, ZeroVec, 6e20, 1e3)
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	pos := Vec3{X: 1e4}
+	// the rotation about X maps the local Y axis to the world Z axis,
+	// so the torque about the world Z axis must be divided by the local Y inertia
+	angle := Vec3{X: math.Pi / 2, Z: 0.3}
+	o, blocks := newTestRig(e, ManMadeObj, planet, pos, angle)
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+
+	mu := planet.Mass() * G
+	var torque Vec3
+	for _, b := range blocks {
+		r := b.Outline().Center().RotatedXYZ(o.Angle())
+		p := o.Pos().Added(r)
+		g := p.ScaledN(-mu / math.Pow(p.Len(), 3))
+		torque.Add(r.Cross(g.ScaledN(b.Mass())))
+	}
+	expect := torque.Z / o.Inertia().Y * time.Millisecond.Seconds()
+
+	h := o.HeadingVel()
+	e.Tick(time.Millisecond)
+	dh := o.HeadingVel().Subbed(h)
+	if expect >= 0 {
+		t.Fatalf("Expect tidal torque aligns the long axis to the planet, got %v", expect)
+	}
+	if math.Abs(dh.Z-expect) > math.Abs(expect)*0.05 || math.Abs(dh.X) > math.Abs(expect)*0.05 || math.Abs(dh.Y) > math.Abs(expect)*0.05 {
+		t.Errorf("Expect heading velocity change (0, 0, %v), got %v", expect, dh)
+	}
+}
+
+func TestTidalDamage(t *testing.T) {
+	e := NewEngine(Config{
+		TidalForces:   true,
+		TidalStrength: 1e-3,
+	})
+	planet := newTestPlanet(e, nil, ZeroVec, 6e20, 1e3)
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	o, blocks := newTestRig(e, ManMadeObj, planet, Vec3{X: 1e4}, ZeroVec)
+	for i := 0; i < 4; i++ {
+		e.Tick(time.Millisecond)
+	}
+	if s := o.TidalStress(); s <= 1e-3 {
+		t.Fatalf("Expect tidal stress above the strength, got %v", s)
+	}
+	// the blocks on the radial axis are stretched the most
+	if d := o.BlockDamage(blocks[0]); d <= 0 {
+		t.Errorf("Expect tidal force damages the block, got %v", d)
+	}
+}
+
+func TestRocheBreakup(t *testing.T) {
+	var splits [][]*Object
+	e := NewEngine(Config{
+		RocheBreakup:   true,
+		RocheFragments: 4,
+		OnObjectSplit: func(o *Object, parts []*Object) {
+			splits = append(splits, parts)
+		},
+	})
+	planet := newTestPlanet(e, nil, ZeroVec, 6e20, 1e3)
+	e.Tick(time.Millisecond)
+	e.Tick(time.Millisecond)
+	o := e.NewObject(NaturalObj, planet, Vec3{X: 1e4}, func(o *Object) {
+		for i := 0; i < 8; i++ {
+			o.AddBlock(newTestBlock(Vec3{X: (float64)(i)}, 1, nil))
+		}
+	})
+	o.SetRadius(4)
+	for i := 0; i < 5; i++ {
+		e.Tick(time.Millisecond)
+	}
+	if len(splits) != 1 || len(splits[0]) != 3 {
+		t.Fatalf("Expect the body breaks into 4 fragments at once, got %v", splits)
+	}
+	if n := len(o.Blocks()); n != 2 {
+		t.Errorf("Expect 2 blocks stay in the body, got %d", n)
+	}
+	for _, p := range splits[0] {
+		if n := len(p.Blocks()); n != 2 {
+			t.Errorf("Expect 2 blocks in each fragment, got %d", n)
+		}
+		if !p.RocheBroken() {
+			t.Errorf("Expect fragment marked as broken")
+		}
+	}
+	// the nearest slice stays in the body
+	for _, b := range o.Blocks() {
+		if x := b.Outline().Center().X; x > 2 {
+			t.Errorf("Expect the nearest blocks stay in the body, got block at %v", x)
+		}
+	}
+}
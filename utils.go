@@ -18,28 +18,56 @@ package molecular
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 // objPool wrapped a sync.Pool
 type objPool[T any] struct {
-	pool sync.Pool // internal sync pool
+	pool   sync.Pool // internal sync pool
+	name   string
+	gets   atomic.Uint64
+	misses atomic.Uint64 // the count of the new allocated objects
 }
 
-func newObjPool[T any]() (p *objPool[T]) {
-	return &objPool[T]{
-		pool: sync.Pool{
-			New: func() any {
-				return new(T)
-			},
-		},
+var (
+	poolsMux sync.Mutex
+	pools    []poolCounter
+)
+
+type poolCounter interface {
+	Stats() PoolStats
+}
+
+func newObjPool[T any](name string) (p *objPool[T]) {
+	p = &objPool[T]{
+		name: name,
+	}
+	p.pool.New = func() any {
+		p.misses.Add(1)
+		return new(T)
 	}
+	poolsMux.Lock()
+	pools = append(pools, p)
+	poolsMux.Unlock()
+	return
 }
 
 func (p *objPool[T]) Get() (ptr *T) {
+	p.gets.Add(1)
 	ptr = p.pool.Get().(*T)
 	return
 }
 
+func (p *objPool[T]) Stats() PoolStats {
+	// load misses first, so it never exceeds gets
+	misses := p.misses.Load()
+	return PoolStats{
+		Name:   p.name,
+		Gets:   p.gets.Load(),
+		Misses: misses,
+	}
+}
+
 func (p *objPool[T]) Put(ptr *T) {
 	p.pool.Put(ptr)
 }
@@ -0,0 +1,278 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ValidationPolicy decides what to do when an object's state becomes invalid
+type ValidationPolicy uint8
+
+const (
+	// ValidateNone disables the validation
+	ValidateNone ValidationPolicy = iota
+	// ValidateReport only reports the invalid state, the state is still committed
+	ValidateReport
+	// ValidateClamp replaces the invalid fields with the last good values,
+	// and clamps the speed below the speed of light
+	ValidateClamp
+	// ValidateFreeze restores the last good state, stops the object,
+	// and quarantines it until Object.Simulate is called
+	ValidateFreeze
+)
+
+func (p ValidationPolicy) String() string {
+	switch p {
+	case ValidateNone:
+		return "none"
+	case ValidateReport:
+		return "report"
+	case ValidateClamp:
+		return "clamp"
+	case ValidateFreeze:
+		return "freeze"
+	default:
+		return fmt.Sprintf("ValidationPolicy(%d)", p)
+	}
+}
+
+// InvalidField is a bitmask of the invalid fields of an object state
+type InvalidField uint8
+
+const (
+	InvalidPos InvalidField = 1 << iota
+	InvalidVelocity
+	InvalidAngle
+	InvalidHeadingVel
+	InvalidMass
+	InvalidGravityCenter
+	// InvalidSpeed means the speed is at or above the speed of light
+	InvalidSpeed
+	// InvalidBounds means the bounds or the outline of any block is not finite
+	InvalidBounds
+)
+
+var invalidFieldNames = [...]string{"pos", "velocity", "angle", "heading-velocity", "mass", "gravity-center", "speed", "bounds"}
+
+func (f InvalidField) String() string {
+	if f == 0 {
+		return "none"
+	}
+	var names []string
+	for i, n := range invalidFieldNames {
+		if f&(1<<i) != 0 {
+			names = append(names, n)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// ObjectState is a snapshot of the kinematic state of an object
+type ObjectState struct {
+	Pos           Vec3
+	Velocity      Vec3
+	Angle         Vec3
+	HeadingVel    Vec3
+	Mass          float64
+	GravityCenter Vec3
+	Bounds        Cube
+}
+
+func (s *objStatus) state() ObjectState {
+	return ObjectState{
+		Pos:           s.pos,
+		Velocity:      s.velocity,
+		Angle:         s.angle,
+		HeadingVel:    s.headVel,
+		Mass:          s.mass,
+		GravityCenter: s.gcenter,
+		Bounds:        s.bounds,
+	}
+}
+
+// InvalidStateEvent will be passed to Config.OnInvalidState when an object's state is invalid
+type InvalidStateEvent struct {
+	Object *Object
+	Id     uuid.UUID
+	Fields InvalidField
+	// State is the invalid state before the policy applied
+	State ObjectState
+	// LastGood is the state of the last tick
+	LastGood ObjectState
+	// Policy is the action that has been taken
+	Policy ValidationPolicy
+}
+
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+func isFiniteVec(v Vec3) bool {
+	return isFinite(v.X) && isFinite(v.Y) && isFinite(v.Z)
+}
+
+func isFiniteCube(c *Cube) bool {
+	return isFiniteVec(c.P) && isFiniteVec(c.S)
+}
+
+// invalidFields checks the status
+func (s *objStatus) invalidFields() (f InvalidField) {
+	if !isFiniteVec(s.pos) {
+		f |= InvalidPos
+	}
+	if !isFiniteVec(s.velocity) {
+		f |= InvalidVelocity
+	} else if s.velocity.SqLen() >= cSq {
+		f |= InvalidSpeed
+	}
+	if !isFiniteVec(s.angle) {
+		f |= InvalidAngle
+	}
+	if !isFiniteVec(s.headVel) {
+		f |= InvalidHeadingVel
+	}
+	if !isFinite(s.mass) {
+		f |= InvalidMass
+	}
+	if !isFiniteVec(s.gcenter) {
+		f |= InvalidGravityCenter
+	}
+	if !isFiniteCube(&s.bounds) {
+		f |= InvalidBounds
+	} else {
+		for _, b := range s.blocks {
+			if !isFiniteCube(b.Outline()) {
+				f |= InvalidBounds
+				break
+			}
+		}
+	}
+	return
+}
+
+// maxValidSpeed is the speed that the clamped velocity will have
+const maxValidSpeed = C * (1 - 1e-9)
+
+// validateLocked checks the next status before it's committed, and applies the validation policy.
+// Restoring the gravity center also restores the position of the gravity field.
+// It must be called during sync with both the locks held
+func (o *Object) validateLocked() {
+	policy := o.e.cfg.Validation
+	if policy == ValidateNone {
+		return
+	}
+	next := &o.nextStatus
+	fields := next.invalidFields()
+	if fields == 0 {
+		return
+	}
+	o.invalid = &InvalidStateEvent{
+		Object:   o,
+		Id:       o.id,
+		Fields:   fields,
+		State:    next.state(),
+		LastGood: o.objStatus.state(),
+		Policy:   policy,
+	}
+	switch policy {
+	case ValidateClamp:
+		if fields&InvalidPos != 0 {
+			next.pos = o.pos
+		}
+		if fields&InvalidVelocity != 0 {
+			next.velocity = o.velocity
+		}
+		if fields&InvalidSpeed != 0 {
+			next.velocity.Normalize().ScaleN(maxValidSpeed)
+		}
+		if fields&InvalidAngle != 0 {
+			next.angle = o.angle
+		}
+		if fields&InvalidHeadingVel != 0 {
+			next.headVel = o.headVel
+		}
+		if fields&InvalidMass != 0 {
+			next.mass = o.mass
+		}
+		if fields&InvalidGravityCenter != 0 {
+			next.gcenter = o.gcenter
+		}
+		if fields&InvalidBounds != 0 {
+			next.bounds = o.bounds
+			o.restoreOutlinesLocked()
+		}
+	case ValidateFreeze:
+		next.pos = o.pos
+		next.velocity = ZeroVec
+		next.angle = o.angle
+		next.headVel = ZeroVec
+		next.mass = o.mass
+		next.gcenter = o.gcenter
+		next.bounds = o.bounds
+		if fields&InvalidBounds != 0 {
+			o.restoreOutlinesLocked()
+		}
+		// the frozen object should not fall asleep or continue its rails later
+		o.sleepPending = false
+		o.sleepTicks = 0
+		o.rails = nil
+		o.railsTime = 0
+		o.simMode.Store((uint32)(Quarantined))
+	}
+}
+
+// restoreOutlinesLocked restores the non-finite block outlines to the ones saved in the block index.
+// The outlines are written in place as Block.Outline allows.
+// The blocks that have never been indexed with a finite outline are left unchanged
+func (o *Object) restoreOutlinesLocked() {
+	for _, b := range o.nextStatus.blocks {
+		if l := b.Outline(); !isFiniteCube(l) {
+			if old, ok := o.index.Outline(b); ok {
+				*l = old
+			}
+		}
+	}
+}
+
+// reportInvalidStates emits the invalid state events recorded during sync
+func (e *Engine) reportInvalidStates() {
+	if e.cfg.Validation == ValidateNone {
+		return
+	}
+	e.RLock()
+	objs := e.objsCache[:0]
+	for _, o := range e.objects {
+		if o.invalid != nil {
+			objs = append(objs, o)
+		}
+	}
+	e.RUnlock()
+
+	for _, o := range objs {
+		event := o.invalid
+		o.invalid = nil
+		if e.cfg.OnInvalidState != nil {
+			e.cfg.OnInvalidState(event)
+		}
+	}
+	e.objsCache = objs[:0]
+}
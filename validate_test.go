@@ -0,0 +1,132 @@
+// molecular is a 3D physics engine written in Go
+// Copyright (C) 2023  Kevin Z <zyxkad@gmail.com>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package molecular_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/LiterMC/molecular"
+)
+
+func TestValidateState(t *testing.T) {
+	var events []*InvalidStateEvent
+	newEngine := func(policy ValidationPolicy) *Engine {
+		events = events[:0]
+		return NewEngine(Config{
+			Validation: policy,
+			OnInvalidState: func(event *InvalidStateEvent) {
+				events = append(events, event)
+			},
+		})
+	}
+	newObject := func(e *Engine) *Object {
+		o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+			o.AddBlock(newTestBlock(ZeroVec, 1, nil))
+			o.SetVelocity(Vec3{X: 1})
+		})
+		e.Tick(time.Millisecond)
+		e.Tick(time.Millisecond)
+		return o
+	}
+
+	e := newEngine(ValidateClamp)
+	o := newObject(e)
+	good := o.Pos()
+	o.SetVelocity(Vec3{X: math.NaN()})
+	e.Tick(time.Millisecond)
+	if len(events) != 1 || events[0].Object != o || events[0].Id != o.Id() {
+		t.Fatalf("Expect one invalid state event of the object, got %v", events)
+	}
+	if f := events[0].Fields; f&InvalidVelocity == 0 {
+		t.Errorf("Expect invalid velocity, got %v", f)
+	}
+	if events[0].LastGood.Pos != good {
+		t.Errorf("Expect last good pos %v, got %v", good, events[0].LastGood.Pos)
+	}
+	if p, v := o.Pos(), o.Velocity(); p != good || v != (Vec3{X: 1}) {
+		t.Errorf("Expect clamped state %v %v, got %v %v", good, Vec3{X: 1}, p, v)
+	}
+	o.SetVelocity(Vec3{Y: 2 * C})
+	e.Tick(time.Millisecond)
+	if len(events) != 2 || events[1].Fields != InvalidSpeed {
+		t.Fatalf("Expect invalid speed event, got %v", events)
+	}
+	if v := o.Velocity(); v.Len() >= C || v.Y <= 0 {
+		t.Errorf("Expect speed clamped below C, got %v", v)
+	}
+
+	e = newEngine(ValidateFreeze)
+	o = newObject(e)
+	good = o.Pos()
+	o.SetVelocity(Vec3{X: math.Inf(1)})
+	e.Tick(time.Millisecond)
+	if len(events) != 1 || events[0].Policy != ValidateFreeze {
+		t.Fatalf("Expect one freeze event, got %v", events)
+	}
+	if !o.Quarantined() || o.Pos() != good || !o.Velocity().IsZero() {
+		t.Errorf("Expect object frozen at %v, got %v %v", good, o.Pos(), o.Velocity())
+	}
+
+	e = newEngine(ValidateNone)
+	o = newObject(e)
+	o.SetVelocity(Vec3{X: math.NaN()})
+	e.Tick(time.Millisecond)
+	if len(events) != 0 {
+		t.Errorf("Expect validation disabled, got %v", events)
+	}
+}
+
+func TestValidateBounds(t *testing.T) {
+	for _, policy := range []ValidationPolicy{ValidateClamp, ValidateFreeze} {
+		var events []*InvalidStateEvent
+		e := NewEngine(Config{
+			Validation: policy,
+			OnInvalidState: func(event *InvalidStateEvent) {
+				events = append(events, event)
+			},
+		})
+		b := newTestBlock(ZeroVec, 1, nil)
+		o := e.NewObject(ManMadeObj, nil, ZeroVec, func(o *Object) {
+			o.AddBlock(b)
+		})
+		e.Tick(time.Millisecond)
+		e.Tick(time.Millisecond)
+		good := *b.Outline()
+		gc := o.GravityCenter()
+
+		// the block breaks its own outline
+		b.Outline().P.X = math.NaN()
+		e.Tick(time.Millisecond)
+		if len(events) != 1 || events[0].Fields&InvalidBounds == 0 {
+			t.Fatalf("%v: Expect invalid bounds event, got %v", policy, events)
+		}
+		if l := *b.Outline(); l != good {
+			t.Errorf("%v: Expect outline restored to %v, got %v", policy, good, l)
+		}
+		if c := o.GravityCenter(); c != gc {
+			t.Errorf("%v: Expect gravity center %v, got %v", policy, gc, c)
+		}
+		if p := o.GravityField().Pos(); p != gc {
+			t.Errorf("%v: Expect gravity field at %v, got %v", policy, gc, p)
+		}
+		if s := events[0].State.Bounds; !math.IsNaN(s.P.X) {
+			t.Errorf("%v: Expect the invalid bounds in the event, got %v", policy, s)
+		}
+	}
+}
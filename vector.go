@@ -219,6 +219,15 @@ func (v Vec3) Dot(u Vec3) float64 {
 	return v.X*u.X + v.Y*u.Y + v.Z*u.Z
 }
 
+// Cross returns the cross product v × u
+func (v Vec3) Cross(u Vec3) Vec3 {
+	return Vec3{
+		X: v.Y*u.Z - v.Z*u.Y,
+		Y: v.Z*u.X - v.X*u.Z,
+		Z: v.X*u.Y - v.Y*u.X,
+	}
+}
+
 // AngleX returns the angle between the vector and y-axis, about z-axis
 //
 //	Z ^
@@ -259,7 +268,7 @@ func (v *Vec3) RotateX(angle float64) *Vec3 {
 // Rotate around y-axis
 func (v *Vec3) RotateY(angle float64) *Vec3 {
 	s, c := math.Sincos(angle)
-	v.X, v.Z = v.X*s+v.Z*c, v.X*c-v.Z*s
+	v.X, v.Z = v.X*c+v.Z*s, v.Z*c-v.X*s
 	return v
 }
 
@@ -292,9 +301,9 @@ func (v Vec3) RotatedX(angle float64) Vec3 {
 func (v Vec3) RotatedY(angle float64) Vec3 {
 	s, c := math.Sincos(angle)
 	return Vec3{
-		X: v.X*s + v.Z*c,
+		X: v.X*c + v.Z*s,
 		Y: v.Y,
-		Z: v.X*c - v.Z*s,
+		Z: v.Z*c - v.X*s,
 	}
 }
 
@@ -314,6 +323,21 @@ func (v Vec3) RotatedXYZ(angles Vec3) Vec3 {
 	return w
 }
 
+// UnrotateXYZ is the inverse operation of RotateXYZ
+func (v *Vec3) UnrotateXYZ(angles Vec3) *Vec3 {
+	return v.
+		RotateZ(-angles.Z).
+		RotateY(-angles.Y).
+		RotateX(-angles.X)
+}
+
+// UnrotatedXYZ is the inverse operation of RotatedXYZ
+func (v Vec3) UnrotatedXYZ(angles Vec3) Vec3 {
+	w := v
+	w.UnrotateXYZ(angles)
+	return w
+}
+
 type Vec4 struct {
 	T, X, Y, Z float64
 }
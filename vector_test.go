@@ -64,6 +64,38 @@ func TestVectorRotateXYZ(t *testing.T) {
 			if !o.Equals(q) {
 				t.Errorf("Rotated pos not equal: %v => (%v, %v)", p, q, o)
 			}
+			if u := o.UnrotatedXYZ(a); u.Subbed(p).SqLen() > 1e-20 {
+				t.Errorf("Unrotated pos not equal: %v => %v => %v", p, o, u)
+			}
+		}
+	}
+}
+
+func TestVectorRotateRightHanded(t *testing.T) {
+	// all the rotations should follow the right-hand rule about their axes,
+	// and preserve the length
+	cases := []struct {
+		rotate func(Vec3, float64) Vec3
+		axis   Vec3
+	}{
+		{Vec3.RotatedX, UnitX},
+		{Vec3.RotatedY, UnitY},
+		{Vec3.RotatedZ, UnitZ},
+	}
+	for _, c := range cases {
+		for _, v := range []Vec3{UnitX, UnitY, UnitZ} {
+			got := c.rotate(v, math.Pi/2)
+			expect := c.axis.Cross(v).Added(c.axis.ScaledN(c.axis.Dot(v)))
+			if got.Subbed(expect).SqLen() > 1e-20 {
+				t.Errorf("Expect %v rotated about %v by 90 degrees to be %v, got %v", v, c.axis, expect, got)
+			}
+			p := v
+			if c.axis == UnitY {
+				p.RotateY(math.Pi / 2)
+				if !p.Equals(got) {
+					t.Errorf("Expect RotateY equals RotatedY, got %v and %v", p, got)
+				}
+			}
 		}
 	}
 }